@@ -0,0 +1,365 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+type accountPayload struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// Password is only required for new accounts.
+	Password *string `json:"password,omitempty"`
+	// States are those whose offices and stations a non-admin may edit, see mayEdit.
+	States *[]uuid.UUID `json:"states,omitempty"`
+}
+
+func (ap *accountPayload) validate() *apiError {
+	if ap.Name = strings.TrimSpace(ap.Name); ap.Name == "" {
+		return &apiError{code: "validation.account_name_missing", field: "name"}
+	}
+
+	if _, ok := roleRanks[ap.Role]; !ok {
+		return &apiError{code: "validation.role_invalid", field: "role"}
+	}
+
+	if ap.Password != nil && len([]rune(*ap.Password)) < 8 {
+		return &apiError{code: "validation.password_too_short", field: "password"}
+	}
+
+	return nil
+}
+
+// hash returns the bcrypt hash of the password, if any.
+func (ap *accountPayload) hash() ([]byte, error) {
+	if ap.Password == nil {
+		return nil, nil
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(*ap.Password), bcryptCost)
+}
+
+// accountNameTaken tells whether the bootstrap superuser or an account other than extId already has name.
+func accountNameTaken(tx storeTx, name string, extId uuid.UUID) (bool, error) {
+	if name == adminName {
+		return true, nil
+	}
+
+	other, found, errAN := tx.accountByName(name)
+	return found && other.Id != extId, errAN
+}
+
+// statesExist tells whether all states exist, if any are given.
+func statesExist(tx storeTx, states *[]uuid.UUID) (bool, error) {
+	if states != nil {
+		for _, state := range *states {
+			if _, ok, errSt := tx.state(state); errSt != nil || !ok {
+				return false, errSt
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// putGrants replaces the grants of account unless states is nil.
+func putGrants(tx storeTx, account uuid.UUID, states *[]uuid.UUID) error {
+	if states == nil {
+		return nil
+	}
+
+	return tx.replaceGrants(account, *states)
+}
+
+func putAccounts(ctx iris.Context) {
+	var payload accountPayload
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if payload.Password == nil {
+		respondError(ctx, apiError{code: "validation.password_missing", field: "password"})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	hash, errHs := payload.hash()
+	if errHs != nil {
+		respondInternal(ctx, errHs)
+		return
+	}
+
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+	var taken, foundStates bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errNT error
+			if taken, errNT = accountNameTaken(tx, payload.Name, uid); errNT != nil || taken {
+				return errNT
+			}
+
+			var errSE error
+			if foundStates, errSE = statesExist(tx, payload.States); errSE != nil || !foundStates {
+				return errSE
+			}
+
+			return audited(tx, p, "account", uid, func() error {
+				if errPA := tx.putAccount(accountRecord{uid, payload.Name, hash, payload.Role}); errPA != nil {
+					return errPA
+				}
+
+				return putGrants(tx, uid, payload.States)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if taken {
+		respondError(ctx, apiError{code: "conflict.account_name_taken", field: "name"})
+		return
+	}
+
+	if !foundStates {
+		respondError(ctx, apiError{code: "not_found.state", field: "states"})
+		return
+	}
+
+	ctx.StatusCode(201)
+	ctx.JSON(struct {
+		Id uuid.UUID `json:"id"`
+	}{uid})
+}
+
+func getAccounts(ctx iris.Context) {
+	var accounts []accountRecord
+	var grants map[uuid.UUID][]uuid.UUID
+
+	{
+		errTx := doTx(true, func(tx storeTx) error {
+			var errAc error
+			if accounts, errAc = tx.accounts(); errAc != nil {
+				return errAc
+			}
+
+			grants = make(map[uuid.UUID][]uuid.UUID, len(accounts))
+
+			for _, row := range accounts {
+				states, errGr := tx.grants(row.Id)
+				if errGr != nil {
+					return errGr
+				}
+
+				grants[row.Id] = append([]uuid.UUID{}, states...)
+			}
+
+			return nil
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	res := make(map[uuid.UUID]accountPayload, len(accounts))
+
+	for _, row := range accounts {
+		states := grants[row.Id]
+		res[row.Id] = accountPayload{Name: row.Name, Role: row.Role, States: &states}
+	}
+
+	ctx.JSON(res)
+}
+
+func getAccount(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	var account accountRecord
+	var found bool
+	var states []uuid.UUID
+	var version int64
+
+	{
+		errTx := doTx(true, func(tx storeTx) error {
+			var errAc error
+			if account, found, errAc = tx.account(extId); errAc != nil || !found {
+				return errAc
+			}
+
+			if version, _, errAc = tx.version("account", extId); errAc != nil {
+				return errAc
+			}
+
+			grants, errGr := tx.grants(extId)
+			if errGr != nil {
+				return errGr
+			}
+
+			states = append([]uuid.UUID{}, grants...)
+			return nil
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.Header("ETag", versionTag(version, nil))
+		ctx.JSON(struct {
+			Id uuid.UUID `json:"id"`
+			accountPayload
+		}{account.Id, accountPayload{Name: account.Name, Role: account.Role, States: &states}})
+	} else {
+		respondError(ctx, apiError{code: "not_found.account"})
+	}
+}
+
+func postAccounts(ctx iris.Context) {
+	var payload accountPayload
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	hash, errHs := payload.hash()
+	if errHs != nil {
+		respondInternal(ctx, errHs)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, taken, foundStates, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "account", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			var errNT error
+			if taken, errNT = accountNameTaken(tx, payload.Name, extId); errNT != nil || taken {
+				return errNT
+			}
+
+			var errSE error
+			if foundStates, errSE = statesExist(tx, payload.States); errSE != nil || !foundStates {
+				return errSE
+			}
+
+			errAu := audited(tx, p, "account", extId, func() error {
+				var errUA error
+				found, errUA = tx.updateAccount(accountRecord{extId, payload.Name, hash, payload.Role})
+				if errUA != nil || !found {
+					return errUA
+				}
+
+				return putGrants(tx, extId, payload.States)
+			})
+			if errAu != nil || !found || hash == nil {
+				return errAu
+			}
+
+			// The snapshots leave out the hash, so they don't tell about a new password.
+			return recordAudit(tx, p, "password_reset", "account", extId, nil, nil)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if taken {
+		respondError(ctx, apiError{code: "conflict.account_name_taken", field: "name"})
+	} else if !foundStates {
+		respondError(ctx, apiError{code: "not_found.state", field: "states"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.account"})
+	}
+}
+
+func deleteAccounts(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "account", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "account", extId, func() error {
+				var errDA error
+				found, errDA = tx.deleteAccount(extId)
+				return errDA
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.account"})
+	}
+}
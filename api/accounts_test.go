@@ -0,0 +1,105 @@
+package main
+
+import (
+	"github.com/gavv/httpexpect"
+	"github.com/google/uuid"
+	"testing"
+)
+
+// newTestAccount creates an account with role and returns a client authenticated as it.
+func newTestAccount(anon, admin *httpexpect.Expect, name, role string) (id string, client *httpexpect.Expect) {
+	id = create(admin, "/v1/accounts", map[string]string{"name": name, "password": name + "-password", "role": role})
+	client = anon.Builder(func(req *httpexpect.Request) {
+		req.WithBasicAuth(name, name+"-password")
+	})
+
+	return
+}
+
+func TestAccountsRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	admin.GET("/v1/accounts").Expect().Status(200).JSON().Object().Empty()
+
+	id, editor := newTestAccount(anon, admin, "editor", "editor")
+
+	admin.GET("/v1/accounts").Expect().Status(200).JSON().Object().
+		Equal(map[string]interface{}{
+			id: map[string]interface{}{"name": "editor", "role": "editor", "states": []string{}},
+		})
+
+	create(editor, "/v1/states", map[string]string{"ru_name": "Германия"})
+
+	// Leaving out the password keeps it.
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]string{"name": "editor", "role": "viewer"}).
+		Expect().Status(204)
+
+	editor.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Австрия"}).
+		Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.role")
+
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]string{
+		"name": "editor", "role": "editor", "password": "new password",
+	}).Expect().Status(204)
+
+	editor.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Австрия"}).
+		Expect().Status(401).JSON().Object().ValueEqual("code", "unauthorized")
+
+	anon.PUT("/v1/states").WithBasicAuth("editor", "new password").WithJSON(map[string]string{"ru_name": "Австрия"}).
+		Expect().Status(201)
+
+	admin.DELETE("/v1/accounts/" + id).Expect().Status(204)
+	admin.DELETE("/v1/accounts/" + id).Expect().Status(404)
+
+	anon.PUT("/v1/states").WithBasicAuth("editor", "new password").WithJSON(map[string]string{"ru_name": "Австрия"}).
+		Expect().Status(401)
+}
+
+func TestAccountsRoles(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	_, editor := newTestAccount(anon, admin, "editor", "editor")
+	_, viewer := newTestAccount(anon, admin, "viewer", "viewer")
+	id, other := newTestAccount(anon, admin, "admin2", "admin")
+
+	for _, client := range []*httpexpect.Expect{editor, viewer} {
+		client.GET("/v1/accounts").Expect().Status(403)
+
+		client.PUT("/v1/accounts").WithJSON(map[string]string{"name": "x", "password": "12345678", "role": "admin"}).
+			Expect().Status(403)
+
+		client.POST("/v1/accounts/" + id).WithJSON(map[string]string{"name": "admin2", "role": "viewer"}).
+			Expect().Status(403)
+	}
+
+	viewer.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).Expect().Status(403)
+	viewer.GET("/v1/states").Expect().Status(200)
+
+	other.GET("/v1/accounts").Expect().Status(200).JSON().Object().Keys().Length().Equal(3)
+}
+
+func TestAccountsValidation(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	id, _ := newTestAccount(anon, admin, "editor", "editor")
+
+	for payload, code := range map[[3]string]string{
+		{" ", "12345678", "editor"}:          "validation.account_name_missing",
+		{"x", "1234567", "editor"}:           "validation.password_too_short",
+		{"x", "12345678", "superuser"}:       "validation.role_invalid",
+		{"editor", "12345678", "admin"}:      "conflict.account_name_taken",
+		{testAdminName, "12345678", "admin"}: "conflict.account_name_taken",
+	} {
+		admin.PUT("/v1/accounts").WithJSON(map[string]string{
+			"name": payload[0], "password": payload[1], "role": payload[2],
+		}).Expect().JSON().Object().ValueEqual("code", code)
+	}
+
+	admin.PUT("/v1/accounts").WithJSON(map[string]string{"name": "x", "role": "editor"}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.password_missing")
+
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]string{"name": testAdminName, "role": "editor"}).
+		Expect().Status(409)
+
+	admin.POST("/v1/accounts/"+uuid.New().String()).WithJSON(map[string]string{"name": "x", "role": "editor"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.account")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"time"
+)
+
+// snapshotters look up what the audit log records about an entity of their type.
+var snapshotters = map[string]func(tx storeTx, id uuid.UUID) (interface{}, bool, error){
+	"state": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return namedSnapshot(tx, id)(tx.state(id))
+	},
+	"office": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		office, ok, errOf := tx.office(id)
+		if errOf != nil || !ok {
+			return nil, false, errOf
+		}
+
+		j, errJu := tx.jurisdiction(id)
+		if errJu != nil {
+			return nil, false, errJu
+		}
+
+		return namedSnapshot(tx, id)(struct {
+			officeRecord
+			Jurisdiction jurisdiction `json:"jurisdiction"`
+		}{office, j}, true, nil)
+	},
+	"station": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return namedSnapshot(tx, id)(tx.station(id))
+	},
+	"district": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return namedSnapshot(tx, id)(tx.district(id))
+	},
+	"candidate": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return namedSnapshot(tx, id)(tx.candidate(id))
+	},
+	"recommendation": func(tx storeTx, district uuid.UUID) (interface{}, bool, error) {
+		return tx.recommendation(district)
+	},
+	"election": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return namedSnapshot(tx, id)(tx.election(id))
+	},
+	"account": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		account, ok, errAc := tx.account(id)
+		if errAc != nil || !ok {
+			return nil, false, errAc
+		}
+
+		states, errGr := tx.grants(id)
+		if errGr != nil {
+			return nil, false, errGr
+		}
+
+		return struct {
+			accountRecord
+			States []uuid.UUID `json:"states"`
+		}{account, append([]uuid.UUID{}, states...)}, true, nil
+	},
+	"token": func(tx storeTx, id uuid.UUID) (interface{}, bool, error) {
+		return tx.token(id)
+	},
+}
+
+// namedSnapshot adds the translations of id to the result of a lookup.
+func namedSnapshot(tx storeTx, id uuid.UUID) func(record interface{}, ok bool, err error) (interface{}, bool, error) {
+	return func(record interface{}, ok bool, err error) (interface{}, bool, error) {
+		if err != nil || !ok {
+			return nil, false, err
+		}
+
+		names, errNs := tx.names(id)
+		if errNs != nil {
+			return nil, false, errNs
+		}
+
+		return struct {
+			Record interface{}       `json:"record"`
+			Names  map[string]string `json:"names"`
+		}{record, names}, true, nil
+	}
+}
+
+// snapshot returns the JSON the audit log records about the entity id of entityType, nil if it doesn't exist.
+func snapshot(tx storeTx, entityType string, id uuid.UUID) ([]byte, error) {
+	record, ok, errSn := snapshotters[entityType](tx, id)
+	if errSn != nil || !ok {
+		return nil, errSn
+	}
+
+	return json.Marshal(record)
+}
+
+// audited runs change and records in the audit log what it did to the entity id of entityType as p's doing.
+// Changes of nothing, e.g. updates of missing records or to the same values, aren't recorded.
+func audited(tx storeTx, p principal, entityType string, id uuid.UUID, change func() error) error {
+	return auditedAs(tx, p, "", entityType, id, change)
+}
+
+// auditedAs is like audited, but records action unless empty instead of deriving it from the snapshots.
+func auditedAs(tx storeTx, p principal, action, entityType string, id uuid.UUID, change func() error) error {
+	before, errBf := snapshot(tx, entityType, id)
+	if errBf != nil {
+		return errBf
+	}
+
+	if errCh := change(); errCh != nil {
+		return errCh
+	}
+
+	after, errAf := snapshot(tx, entityType, id)
+	if errAf != nil {
+		return errAf
+	}
+
+	switch {
+	case bytes.Equal(before, after):
+		return nil
+	case action != "":
+	case before == nil:
+		action = "create"
+	case after == nil:
+		action = "delete"
+	default:
+		action = "update"
+	}
+
+	if _, private := privateKinds[entityType]; !private {
+		if errBR := tx.bumpRevision(); errBR != nil {
+			return errBR
+		}
+
+		if errLC := logChange(tx, entityType, id); errLC != nil {
+			return errLC
+		}
+	}
+
+	return recordAudit(tx, p, action, entityType, id, before, after)
+}
+
+// recordAudit records in the audit log that p did action to the entity id of entityType.
+func recordAudit(tx storeTx, p principal, action, entityType string, id uuid.UUID, before, after []byte) error {
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		return errNR
+	}
+
+	return tx.putAuditEvent(auditRecord{
+		uid, time.Now(), p.Account, p.Name, action, entityType, id, before, after,
+	})
+}
+
+func getAudit(ctx iris.Context) {
+	var entity uuid.UUID
+	var since *time.Time
+
+	if raw := ctx.URLParam("entity"); raw != "" {
+		var errPU error
+		if entity, errPU = uuid.Parse(raw); errPU != nil {
+			respondError(ctx, apiError{"validation.malformed_id", "entity", errPU.Error()})
+			return
+		}
+	}
+
+	if raw := ctx.URLParam("since"); raw != "" {
+		t, errPT := time.Parse(time.RFC3339Nano, raw)
+		if errPT != nil {
+			respondError(ctx, apiError{"validation.since_invalid", "since", errPT.Error()})
+			return
+		}
+
+		since = &t
+	}
+
+	var events []auditRecord
+
+	{
+		errTx := doTx(true, func(tx storeTx) error {
+			var errAE error
+			events, errAE = tx.auditEvents(entity, since)
+			return errAE
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	type actor struct {
+		Id   uuid.UUID `json:"id"`
+		Name string    `json:"name"`
+	}
+
+	type event struct {
+		Id         uuid.UUID       `json:"id"`
+		Time       time.Time       `json:"time"`
+		Actor      actor           `json:"actor"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		Entity     uuid.UUID       `json:"entity"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+	}
+
+	res := make([]event, 0, len(events))
+
+	for _, row := range events {
+		res = append(res, event{
+			row.Id, row.Time, actor{row.Actor, row.ActorName}, row.Action, row.EntityType, row.Entity,
+			rawJson(row.Before), rawJson(row.After),
+		})
+	}
+
+	ctx.JSON(res)
+}
+
+// rawJson is raw as is, null if nil.
+func rawJson(raw []byte) json.RawMessage {
+	if raw == nil {
+		return []byte("null")
+	}
+
+	return raw
+}
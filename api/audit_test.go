@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditRecordsWrites(t *testing.T) {
+	anon, admin := newTestApi(t)
+	accountId, editor := newTestAccount(anon, admin, "editor", "editor")
+
+	state := create(editor, "/v1/states", map[string]interface{}{
+		"ru_name": "Австрия", "names": map[string]string{"en": "Austria"},
+	})
+
+	editor.POST("/v1/states/" + state).WithJSON(map[string]string{"ru_name": "Австрийская Республика"}).
+		Expect().Status(204)
+
+	events := admin.GET("/v1/audit").WithQuery("entity", state).Expect().Status(200).JSON().Array()
+	events.Length().Equal(2)
+
+	created := events.Element(0).Object()
+	created.ValueEqual("action", "create").ValueEqual("entity_type", "state").ValueEqual("entity", state)
+	created.ValueEqual("actor", map[string]string{"id": accountId, "name": "editor"}).ValueEqual("before", nil)
+	created.Value("after").Object().ValueEqual("names", map[string]string{"en": "Austria"}).
+		Value("record").Object().ValueEqual("ru_name", "Австрия")
+
+	updated := events.Element(1).Object()
+	updated.ValueEqual("action", "update")
+	updated.Value("before").Object().Value("record").Object().ValueEqual("ru_name", "Австрия")
+	updated.Value("after").Object().Value("record").Object().ValueEqual("ru_name", "Австрийская Республика")
+
+	// Accounts are audited without their password hashes.
+	account := admin.GET("/v1/audit").WithQuery("entity", accountId).Expect().Status(200).JSON().Array()
+	account.Length().Equal(1)
+	account.Element(0).Object().ValueEqual("actor", map[string]string{
+		"id": "00000000-0000-0000-0000-000000000000", "name": testAdminName,
+	}).Value("after").Object().NotContainsKey("hash").NotContainsKey("Hash").ValueEqual("role", "editor")
+
+	editor.GET("/v1/audit").Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.role")
+
+	// Still password changes are.
+	admin.POST("/v1/accounts/" + accountId).WithJSON(map[string]string{
+		"name": "editor", "password": "new-password", "role": "editor",
+	}).Expect().Status(204)
+
+	account = admin.GET("/v1/audit").WithQuery("entity", accountId).Expect().Status(200).JSON().Array()
+	account.Length().Equal(2)
+	account.Element(1).Object().ValueEqual("action", "password_reset").ValueEqual("after", nil)
+
+	editor.GET("/v1/audit").Expect().Status(401)
+}
+
+func TestAuditCascadesAndRollbacks(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Австрия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Вене"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8101", "district": district,
+	})
+
+	since := time.Now()
+
+	// Failed writes roll back their events, too.
+	admin.DELETE("/v1/states/" + state).Expect().Status(409)
+	admin.GET("/v1/audit").WithQuery("since", since.Format(time.RFC3339Nano)).
+		Expect().Status(200).JSON().Array().Empty()
+
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", true).Expect().Status(204)
+
+	events := admin.GET("/v1/audit").WithQuery("since", since.Format(time.RFC3339Nano)).
+		Expect().Status(200).JSON().Array()
+
+	events.Length().Equal(3)
+
+	for i, id := range []string{station, office, state} {
+		events.Element(i).Object().ValueEqual("action", "delete").ValueEqual("entity", id).ValueEqual("after", nil)
+	}
+
+	admin.GET("/v1/audit").WithQuery("since", "yesterday").Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.since_invalid").ValueEqual("field", "since")
+
+	admin.GET("/v1/audit").Expect().Status(200).JSON().Array().Length().Equal(8)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/sha256"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strings"
+	"time"
+)
+
+// adminName and adminHash are the bootstrap superuser's, it works even without any accounts.
+var adminName string
+var adminHash []byte
+
+// bcryptCost applies to account passwords.
+var bcryptCost = bcrypt.DefaultCost
+
+// roleRanks orders the roles, each one may do everything lower ranked ones may.
+var roleRanks = map[string]int{
+	"viewer": 1,
+	"editor": 2,
+	"admin":  3,
+}
+
+// principal is who authenticated a request, see mustHaveRole.
+type principal struct {
+	// Account is uuid.Nil for the bootstrap superuser.
+	Account uuid.UUID
+	Name    string
+	Role    string
+	// Scopes limit what a token may do, they're nil for passwords which may do everything the role may.
+	Scopes []string
+	// Token is the one used for authentication, if any.
+	Token uuid.UUID
+}
+
+// hasScope tells whether p may use scope. Tokens never may use the empty scope.
+func (p principal) hasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+
+	if scope == "" {
+		return false
+	}
+
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func initAdmin() {
+	adminName = os.Getenv("VOTEAPI_ADMIN_NAME")
+	if adminName == "" {
+		log.WithFields(log.Fields{"var": "VOTEAPI_ADMIN_NAME"}).Fatal("Env var missing")
+	}
+
+	pass := os.Getenv("VOTEAPI_ADMIN_PASSWORD")
+	if pass == "" {
+		log.WithFields(log.Fields{"var": "VOTEAPI_ADMIN_PASSWORD"}).Fatal("Env var missing")
+	}
+
+	var errGF error
+	if adminHash, errGF = bcrypt.GenerateFromPassword([]byte(adminName+":"+pass), bcrypt.DefaultCost); errGF != nil {
+		log.WithFields(log.Fields{
+			"cost": bcrypt.DefaultCost, "error": errGF.Error(),
+		}).Fatal("Couldn't hash admin credentials")
+	}
+}
+
+// authenticate checks the request's bearer token or basic auth against the bootstrap superuser and the accounts.
+func authenticate(ctx iris.Context) (principal, bool, error) {
+	if bearer := ctx.GetHeader("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		return authenticateToken(strings.TrimSpace(strings.TrimPrefix(bearer, "Bearer ")))
+	}
+
+	user, pass, ok := ctx.Request().BasicAuth()
+	if !ok {
+		return principal{}, false, nil
+	}
+
+	if user == adminName {
+		if bcrypt.CompareHashAndPassword(adminHash, []byte(user+":"+pass)) != nil {
+			return principal{}, false, nil
+		}
+
+		return principal{uuid.Nil, adminName, "admin", nil, uuid.Nil}, true, nil
+	}
+
+	var account accountRecord
+
+	errTx := doTx(true, func(tx storeTx) error {
+		var errAN error
+		account, ok, errAN = tx.accountByName(user)
+		return errAN
+	})
+	if errTx != nil || !ok || bcrypt.CompareHashAndPassword(account.Hash, []byte(pass)) != nil {
+		return principal{}, false, errTx
+	}
+
+	return principal{account.Id, account.Name, account.Role, nil, uuid.Nil}, true, nil
+}
+
+// tokenTouchInterval limits how often a token's last usage is written back.
+const tokenTouchInterval = time.Minute
+
+// authenticateToken checks an API token and acts on behalf of its owner's current role with its scopes.
+func authenticateToken(secret string) (principal, bool, error) {
+	hash := sha256.Sum256([]byte(secret))
+	now := time.Now()
+	var p principal
+	var ok bool
+
+	errTx := doTx(false, func(tx storeTx) error {
+		token, found, errTH := tx.tokenByHash(hash[:])
+		if errTH != nil || !found || token.Expires != nil && !now.Before(*token.Expires) {
+			return errTH
+		}
+
+		if token.Account == uuid.Nil {
+			p = principal{uuid.Nil, adminName, "admin", token.Scopes, token.Id}
+		} else {
+			account, found, errAc := tx.account(token.Account)
+			if errAc != nil || !found {
+				return errAc
+			}
+
+			p = principal{account.Id, account.Name, account.Role, token.Scopes, token.Id}
+		}
+
+		if p.Scopes == nil {
+			p.Scopes = []string{}
+		}
+
+		ok = true
+
+		if token.LastUsed == nil || now.Sub(*token.LastUsed) >= tokenTouchInterval {
+			return tx.touchToken(token.Id, now)
+		}
+
+		return nil
+	})
+	if errTx != nil || !ok {
+		return principal{}, false, errTx
+	}
+
+	return p, true, nil
+}
+
+// mustHaveRole lets only requests authenticated with at least role through.
+// Tokens must also have scope, the empty one is reserved for passwords.
+func mustHaveRole(role, scope string) iris.Handler {
+	return func(ctx iris.Context) {
+		if _, ok := authorize(ctx, role, scope); ok {
+			ctx.Next()
+		}
+	}
+}
+
+// authorize is like mustHaveRole, but for handlers. It responds to the request unless it's authorized.
+func authorize(ctx iris.Context, role, scope string) (principal, bool) {
+	p, ok, errAu := authenticate(ctx)
+	if errAu != nil {
+		respondInternal(ctx, errAu)
+		return principal{}, false
+	}
+
+	if !ok {
+		respondError(ctx, apiError{code: "unauthorized"})
+		return principal{}, false
+	}
+
+	if roleRanks[p.Role] < roleRanks[role] {
+		respondError(ctx, apiError{code: "forbidden.role"})
+		return principal{}, false
+	}
+
+	if !p.hasScope(scope) {
+		respondError(ctx, apiError{code: "forbidden.scope"})
+		return principal{}, false
+	}
+
+	ctx.Values().Set("principal", p)
+	return p, true
+}
+
+// principalOf returns who authenticated the request, see mustHaveRole.
+func principalOf(ctx iris.Context) principal {
+	p, _ := ctx.Values().Get("principal").(principal)
+	return p
+}
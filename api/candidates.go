@@ -0,0 +1,345 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"strings"
+)
+
+var nominationStatuses = map[string]struct{}{
+	"nominated":  {},
+	"registered": {},
+	"refused":    {},
+	"withdrawn":  {},
+}
+
+type candidatePayload struct {
+	RuName string            `json:"ru_name"`
+	Party  string            `json:"party"`
+	Status string            `json:"status"`
+	Names  map[string]string `json:"names"`
+}
+
+func (cp *candidatePayload) validate() *apiError {
+	if strings.TrimSpace(cp.RuName) == "" {
+		return &apiError{code: "validation.ru_name_missing", field: "ru_name"}
+	}
+
+	if strings.TrimSpace(cp.Party) == "" {
+		return &apiError{code: "validation.party_missing", field: "party"}
+	}
+
+	if _, ok := nominationStatuses[cp.Status]; !ok {
+		return &apiError{code: "validation.status_invalid", field: "status"}
+	}
+
+	return nil
+}
+
+func putCandidates(ctx iris.Context) {
+	var payload candidatePayload
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+	var found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			_, ok, errDs := tx.district(extId)
+			if errDs != nil {
+				return errDs
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			return audited(tx, p, "candidate", uid, func() error {
+				errPC := tx.putCandidate(candidateRecord{uid, extId, payload.RuName, payload.Party, payload.Status})
+				if errPC != nil {
+					return errPC
+				}
+
+				return putNames(tx, uid, names)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.StatusCode(201)
+		ctx.JSON(struct {
+			Id uuid.UUID `json:"id"`
+		}{uid})
+	} else {
+		respondError(ctx, apiError{code: "not_found.district"})
+	}
+}
+
+func getCandidates(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var found bool
+	var candidates []candidateRecord
+	var localized map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			_, ok, errDs := tx.district(extId)
+			if errDs != nil {
+				return errDs
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			var errCs error
+			if candidates, errCs = tx.candidates(extId); errCs != nil {
+				return errCs
+			}
+
+			localized = make(map[uuid.UUID]string, len(candidates))
+
+			for _, row := range candidates {
+				localized[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, localized)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		type candidate struct {
+			RuName string `json:"ru_name"`
+			Name   string `json:"name"`
+			Party  string `json:"party"`
+			Status string `json:"status"`
+		}
+
+		res := make(map[uuid.UUID]candidate, len(candidates))
+
+		for _, row := range candidates {
+			res[row.Id] = candidate{row.RuName, localized[row.Id], row.Party, row.Status}
+		}
+
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.district"})
+	}
+}
+
+func getCandidate(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var candidate candidateRecord
+	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errCd error
+			if candidate, found, errCd = tx.candidate(extId); errCd != nil || !found {
+				return errCd
+			}
+
+			if version, _, errCd = tx.version("candidate", extId); errCd != nil {
+				return errCd
+			}
+
+			name = map[uuid.UUID]string{candidate.Id: candidate.RuName}
+			return localize(tx, prefs, name)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			Id       uuid.UUID `json:"id"`
+			District uuid.UUID `json:"district"`
+			RuName   string    `json:"ru_name"`
+			Name     string    `json:"name"`
+			Party    string    `json:"party"`
+			Status   string    `json:"status"`
+		}{candidate.Id, candidate.District, candidate.RuName, name[candidate.Id], candidate.Party, candidate.Status})
+	} else {
+		respondError(ctx, apiError{code: "not_found.candidate"})
+	}
+}
+
+func postCandidates(ctx iris.Context) {
+	var payload candidatePayload
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "candidate", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "candidate", extId, func() error {
+				ok, errUC := tx.updateCandidate(candidateRecord{
+					Id: extId, RuName: payload.RuName, Party: payload.Party, Status: payload.Status,
+				})
+				if errUC != nil {
+					return errUC
+				}
+
+				if found = ok; !found {
+					return nil
+				}
+
+				return putNames(tx, extId, names)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.candidate"})
+	}
+}
+
+func deleteCandidates(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "candidate", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "candidate", extId, func() error {
+				var errDC error
+				found, errDC = tx.deleteCandidate(extId)
+				return errDC
+			})
+		})
+		if errTx != nil {
+			if referenced(errTx) {
+				respondReferenced(ctx, "conflict.candidate_referenced", extId, candidateDependents)
+			} else {
+				respondInternal(ctx, errTx)
+			}
+
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.candidate"})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestCandidatesRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	candidates := "/v1/districts/" + district + "/candidates"
+
+	anon.GET(candidates).Expect().Status(200).JSON().Object().Empty()
+
+	id := create(admin, candidates, map[string]interface{}{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "nominated",
+		"names": map[string]string{"en": "Ivan Ivanov"},
+	})
+
+	candidate := anon.GET(candidates).WithQuery("lang", "en").Expect().Status(200).JSON().Object().
+		Value(id).Object()
+
+	candidate.ValueEqual("ru_name", "Иванов Иван Иванович").ValueEqual("name", "Ivan Ivanov")
+	candidate.ValueEqual("party", "Самовыдвижение").ValueEqual("status", "nominated")
+
+	admin.POST("/v1/candidates/" + id).WithJSON(map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	}).Expect().Status(204)
+
+	anon.GET(candidates).Expect().Status(200).JSON().Object().Value(id).Object().ValueEqual("status", "registered")
+
+	admin.DELETE("/v1/candidates/" + id).Expect().Status(204)
+	admin.DELETE("/v1/candidates/" + id).Expect().Status(404)
+
+	anon.GET(candidates).Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestCandidatesRecommendedCantBeDeleted(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	candidate := create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(204)
+
+	conflict := admin.DELETE("/v1/candidates/" + candidate).Expect().Status(409).JSON().Object()
+	conflict.ValueEqual("code", "conflict.candidate_referenced")
+	conflict.ValueEqual("dependents", map[string][]string{"recommendations": {district}})
+
+	admin.DELETE("/v1/districts/" + district + "/recommendation").Expect().Status(204)
+	admin.DELETE("/v1/candidates/" + candidate).Expect().Status(204)
+}
+
+func TestCandidatesValidation(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	candidates := "/v1/districts/" + create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"}) +
+		"/candidates"
+
+	for payload, code := range map[[3]string]string{
+		{"", "Самовыдвижение", "nominated"}:                     "validation.ru_name_missing",
+		{"Иванов Иван Иванович", " ", "nominated"}:              "validation.party_missing",
+		{"Иванов Иван Иванович", "Самовыдвижение", "elected"}:   "validation.status_invalid",
+		{"Иванов Иван Иванович", "Самовыдвижение", "Nominated"}: "validation.status_invalid",
+	} {
+		admin.PUT(candidates).WithJSON(map[string]string{
+			"ru_name": payload[0], "party": payload[1], "status": payload[2],
+		}).Expect().Status(400).JSON().Object().ValueEqual("code", code)
+	}
+
+	missing := uuid.New().String()
+
+	anon.GET("/v1/districts/" + missing + "/candidates").Expect().Status(404)
+
+	admin.PUT("/v1/districts/"+missing+"/candidates").WithJSON(map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "nominated",
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.district")
+
+	admin.POST("/v1/candidates/"+missing).WithJSON(map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "nominated",
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.candidate")
+}
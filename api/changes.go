@@ -0,0 +1,210 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"strconv"
+	"time"
+)
+
+// changeKinds are the kinds of records getChanges serves.
+var changeKinds = map[string]struct{}{"state": {}, "office": {}, "station": {}, "district": {}}
+
+// endOfTime is later than any publication.
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// logChange logs a change of the record id of kind for getChanges unless it doesn't serve the kind.
+func logChange(tx storeTx, kind string, id uuid.UUID) error {
+	if _, ok := changeKinds[kind]; !ok {
+		return nil
+	}
+
+	return tx.putChange(kind, id)
+}
+
+// scheduled lists the drafts whose publication is scheduled after at, i.e. all but the unpublished ones.
+func scheduled(tx storeTx, at time.Time) (map[draftRecord]struct{}, error) {
+	hidden, errDr := tx.drafts(at)
+	if errDr != nil {
+		return nil, errDr
+	}
+
+	unpublished, errDr := tx.drafts(endOfTime)
+	if errDr != nil {
+		return nil, errDr
+	}
+
+	res := make(map[draftRecord]struct{}, len(hidden))
+	for _, draft := range hidden {
+		res[draft] = struct{}{}
+	}
+
+	for _, draft := range unpublished {
+		delete(res, draft)
+	}
+
+	return res, nil
+}
+
+type changedState struct {
+	RuName  string  `json:"ru_name"`
+	Name    string  `json:"name"`
+	IsoCode *string `json:"iso_code"`
+}
+
+type changedOffice struct {
+	State  uuid.UUID `json:"state"`
+	RuName string    `json:"ru_name"`
+	Name   string    `json:"name"`
+	location
+}
+
+type changedStation struct {
+	Office   uuid.UUID `json:"office"`
+	District uuid.UUID `json:"district"`
+	RuName   string    `json:"ru_name"`
+	Name     string    `json:"name"`
+	location
+}
+
+type changedDistrict struct {
+	Election uuid.UUID `json:"election"`
+	RuName   string    `json:"ru_name"`
+	Name     string    `json:"name"`
+}
+
+type change struct {
+	Revision int64     `json:"revision"`
+	Kind     string    `json:"kind"`
+	Id       uuid.UUID `json:"id"`
+	// Deleted tells that the record is gone (or a draft) and Record is missing.
+	Deleted bool        `json:"deleted"`
+	Record  interface{} `json:"record,omitempty"`
+}
+
+// getChanges serves the states, offices, stations and districts changed after ?since (a revision), oldest first,
+// and the revision to pass as ?since next time. That one is kept before the changes of scheduled drafts until
+// they're published.
+func getChanges(ctx iris.Context) {
+	var since int64
+	if raw := ctx.URLParam("since"); raw != "" {
+		var errPI error
+		if since, errPI = strconv.ParseInt(raw, 10, 64); errPI != nil || since < 0 {
+			respondError(ctx, apiError{code: "validation.revision_invalid", field: "since"})
+			return
+		}
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var cursor int64
+	var changes []changeRecord
+	var records map[uuid.UUID]interface{}
+	var localized map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			now := time.Now()
+
+			rev, errRv := tx.revision(now)
+			if errRv != nil {
+				return errRv
+			}
+
+			if changes, errRv = tx.changes(since); errRv != nil {
+				return errRv
+			}
+
+			cursor = rev.Revision
+			records = map[uuid.UUID]interface{}{}
+			localized = map[uuid.UUID]string{}
+
+			for _, c := range changes {
+				var record interface{}
+				var ruName string
+				var found bool
+				var errLU error
+
+				switch c.Kind {
+				case "state":
+					var row stateRecord
+					row, found, errLU = tx.state(c.Id)
+					record, ruName = row, row.RuName
+				case "office":
+					var row officeRecord
+					row, found, errLU = tx.office(c.Id)
+					record, ruName = row, row.RuName
+				case "station":
+					var row stationRecord
+					row, found, errLU = tx.station(c.Id)
+					record, ruName = row, row.RuName
+				case "district":
+					var row districtRecord
+					row, found, errLU = tx.district(c.Id)
+					record, ruName = row, row.RuName
+				}
+
+				if errLU != nil {
+					return errLU
+				}
+
+				if found {
+					records[c.Id] = record
+					localized[c.Id] = ruName
+				}
+			}
+
+			if !drafts {
+				pending, errSc := scheduled(tx, now)
+				if errSc != nil {
+					return errSc
+				}
+
+				for _, c := range changes {
+					if _, ok := pending[draftRecord{c.Kind, c.Id}]; ok && c.Revision <= cursor {
+						cursor = c.Revision - 1
+					}
+				}
+			}
+
+			return localize(tx, prefs, localized)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	res := struct {
+		Revision int64    `json:"revision"`
+		Changes  []change `json:"changes"`
+	}{cursor, make([]change, 0, len(changes))}
+
+	for _, c := range changes {
+		entry := change{c.Revision, c.Kind, c.Id, true, nil}
+
+		switch row := records[c.Id].(type) {
+		case stateRecord:
+			entry.Record = changedState{row.RuName, localized[row.Id], row.IsoCode}
+		case officeRecord:
+			entry.Record = changedOffice{
+				row.State, row.RuName, localized[row.Id], location{row.Lat, row.Lon, row.Address},
+			}
+		case stationRecord:
+			entry.Record = changedStation{
+				row.Office, row.District, row.RuName, localized[row.Id], location{row.Lat, row.Lon, row.Address},
+			}
+		case districtRecord:
+			entry.Record = changedDistrict{row.Election, row.RuName, localized[row.Id]}
+		}
+
+		entry.Deleted = entry.Record == nil
+		res.Changes = append(res.Changes, entry)
+	}
+
+	ctx.JSON(res)
+}
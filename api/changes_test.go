@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangesSync(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия", "iso_code": "DE"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "lat": 52.5, "lon": 13.4,
+	})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	feed := anon.GET("/v1/changes").Expect().Status(200).JSON().Object()
+	changes := feed.Value("changes").Array()
+	changes.Length().Equal(4)
+
+	changes.Element(0).Object().ValueEqual("kind", "district").ValueEqual("id", district).
+		ValueEqual("deleted", false).Value("record").Object().ValueEqual("ru_name", "Округ №1")
+	changes.Element(1).Object().ValueEqual("kind", "state").Value("record").Object().ValueEqual("iso_code", "DE")
+	changes.Element(2).Object().ValueEqual("kind", "office").Value("record").Object().
+		ValueEqual("state", state).ValueEqual("lat", 52.5)
+	changes.Element(3).Object().ValueEqual("kind", "station").Value("record").Object().
+		ValueEqual("office", office).ValueEqual("district", district)
+
+	since := feed.Value("revision").Number().Raw()
+	anon.GET("/v1/changes").WithQuery("since", since).Expect().Status(200).JSON().Object().
+		ValueEqual("revision", since).Value("changes").Array().Empty()
+
+	// Only the latest change of each record is served.
+	admin.POST("/v1/states/" + state).WithJSON(map[string]interface{}{
+		"ru_name": "ФРГ", "names": map[string]string{"en": "Germany"},
+	}).Expect().Status(204)
+	admin.DELETE("/v1/stations/" + station).Expect().Status(204)
+	admin.DELETE("/v1/offices/" + office).Expect().Status(204)
+
+	feed = anon.GET("/v1/changes").WithQuery("since", since).WithHeader("Accept-Language", "en").
+		Expect().Status(200).JSON().Object()
+	changes = feed.Value("changes").Array()
+	changes.Length().Equal(3)
+
+	changes.Element(0).Object().ValueEqual("id", state).Value("record").Object().
+		ValueEqual("ru_name", "ФРГ").ValueEqual("name", "Germany")
+	changes.Element(1).Object().ValueEqual("id", station).ValueEqual("deleted", true).NotContainsKey("record")
+	changes.Element(2).Object().ValueEqual("id", office).ValueEqual("deleted", true)
+
+	since = feed.Value("revision").Number().Raw()
+	admin.POST("/v1/offices/" + office + "/restore").Expect().Status(204)
+
+	changes = anon.GET("/v1/changes").WithQuery("since", since).Expect().Status(200).JSON().Object().
+		Value("changes").Array()
+	changes.Length().Equal(1)
+	changes.Element(0).Object().ValueEqual("id", office).ValueEqual("deleted", false)
+
+	anon.GET("/v1/changes").WithQuery("since", -1).Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.revision_invalid").ValueEqual("field", "since")
+	anon.GET("/v1/changes").WithQuery("since", "latest").Expect().Status(400)
+}
+
+func TestChangesDrafts(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	since := anon.GET("/v1/changes").Expect().Status(200).JSON().Object().Value("revision").Number().Raw()
+	state := admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).
+		Expect().Status(201).JSON().Object().Value("id").String().Raw()
+
+	// Drafts look deleted to the public.
+	feed := anon.GET("/v1/changes").WithQuery("since", since).Expect().Status(200).JSON().Object()
+	feed.Value("changes").Array().Element(0).Object().ValueEqual("id", state).ValueEqual("deleted", true)
+	since = feed.Value("revision").Number().Raw()
+
+	admin.GET("/v1/changes").WithQuery("include", "drafts").Expect().Status(200).JSON().Object().
+		Value("changes").Array().Element(0).Object().ValueEqual("deleted", false)
+
+	admin.POST("/v1/publish").WithJSON(map[string]interface{}{"at": time.Now().Add(time.Second / 2)}).
+		Expect().Status(200)
+
+	// The revision doesn't move past a scheduled publication until it's out.
+	feed = anon.GET("/v1/changes").WithQuery("since", since).Expect().Status(200).JSON().Object()
+	feed.Value("revision").Number().Equal(since)
+	feed.Value("changes").Array().Element(0).Object().ValueEqual("deleted", true)
+
+	time.Sleep(time.Second / 2)
+
+	feed = anon.GET("/v1/changes").WithQuery("since", since).Expect().Status(200).JSON().Object()
+	feed.Value("revision").Number().Gt(since)
+	feed.Value("changes").Array().Element(0).Object().ValueEqual("id", state).ValueEqual("deleted", false)
+}
@@ -3,30 +3,29 @@ package main
 import (
 	"context"
 	"database/sql"
-	"github.com/kataras/iris/v12"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"reflect"
-	"sync"
-	"sync/atomic"
+	"strings"
 )
 
+// db is nil unless the PostgreSQL backend is in use.
 var db *sql.DB
 
-var schemaImport struct {
-	sync.Mutex
-
-	done uint32
-}
-
+// initDb sets up the backend $VOTEAPI_DB refers to, i.e. a PostgreSQL DSN or memory:// for a volatile in-memory store.
 func initDb() {
 	dsn, ok := os.LookupEnv("VOTEAPI_DB")
 	if !ok {
 		log.WithFields(log.Fields{"var": "VOTEAPI_DB"}).Fatal("Env var missing")
 	}
 
+	if strings.HasPrefix(dsn, "memory:") {
+		backend = newMemStore()
+		return
+	}
+
 	{
 		var errOp error
 		if db, errOp = sql.Open("postgres", dsn); errOp != nil {
@@ -36,83 +35,25 @@ func initDb() {
 		}
 	}
 
+	backend = pgStore{db}
+
 	onTerm.ToDo = append(onTerm.ToDo, func() {
 		_ = db.Close()
 	})
 }
 
-func ensureSchema(ctx iris.Context) {
-	if atomic.LoadUint32(&schemaImport.done) == 0 {
-		schemaImport.Lock()
-		defer schemaImport.Unlock()
-
-		if atomic.LoadUint32(&schemaImport.done) == 0 {
-			if errIS := doTx(false, importSchema); errIS != nil {
-				log.WithFields(log.Fields{"error": errIS.Error()}).Error("Couldn't create database schema")
-				ctx.StatusCode(500)
-				return
-			}
-
-			atomic.StoreUint32(&schemaImport.done, 1)
-		}
-	}
-
-	ctx.Next()
+type pgStore struct {
+	db *sql.DB
 }
 
-func importSchema(tx *sql.Tx) error {
-	{
-		_, errEx := tx.Exec(`CREATE TABLE IF NOT EXISTS state (
-	int_id  SMALLSERIAL PRIMARY KEY,
-	ext_id  UUID NOT NULL UNIQUE,
-	ru_name VARCHAR(255) NOT NULL
-)`)
-		if errEx != nil {
-			return errEx
-		}
-	}
-
-	{
-		_, errEx := tx.Exec(`CREATE TABLE IF NOT EXISTS office (
-	int_id  SERIAL PRIMARY KEY,
-	ext_id  UUID NOT NULL UNIQUE,
-	state   SMALLINT NOT NULL REFERENCES state(int_id),
-	ru_name VARCHAR(255) NOT NULL
-)`)
-		if errEx != nil {
-			return errEx
-		}
-	}
-
-	{
-		_, errEx := tx.Exec(`CREATE TABLE IF NOT EXISTS district (
-	int_id  SMALLSERIAL PRIMARY KEY,
-	ext_id  UUID NOT NULL UNIQUE,
-	ru_name VARCHAR(255) NOT NULL
-)`)
-		if errEx != nil {
-			return errEx
-		}
-	}
-
-	_, errEx := tx.Exec(`CREATE TABLE IF NOT EXISTS station (
-	int_id   BIGSERIAL PRIMARY KEY,
-	ext_id   UUID NOT NULL UNIQUE,
-	office   INT NOT NULL REFERENCES office(int_id),
-	district SMALLINT NOT NULL REFERENCES district(int_id),
-	ru_name  VARCHAR(255) NOT NULL
-)`)
-	return errEx
-}
-
-func doTx(ro bool, f func(tx *sql.Tx) error) error {
+func (ps pgStore) tx(ro bool, f func(tx storeTx) error) error {
 	for {
-		tx, errBg := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: ro})
+		tx, errBg := ps.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: ro})
 		if errBg != nil {
 			return errBg
 		}
 
-		if errTx := f(tx); errTx != nil {
+		if errTx := f(pgTx{tx, new(bool)}); errTx != nil {
 			_ = tx.Rollback()
 
 			if retryTx(errTx) {
@@ -141,6 +82,18 @@ func retryTx(err error) bool {
 	return ok && errPq.Code == "40001"
 }
 
+// referenced reports whether err is a foreign key violation of either store.
+func referenced(err error) bool {
+	switch e := err.(type) {
+	case *pq.Error:
+		return e.Code == "23503"
+	case referenceError:
+		return true
+	default:
+		return false
+	}
+}
+
 func fetchAll(db interface {
 	Query(string, ...interface{}) (*sql.Rows, error)
 }, rowType interface{}, query string, args ...interface{}) (interface{}, error) {
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"sort"
+	"strconv"
+)
+
+// dependents are the records still referencing one which is to be deleted, by plural kind.
+type dependents map[string][]uuid.UUID
+
+func (d dependents) add(kind string, id uuid.UUID) {
+	d[kind] = append(d[kind], id)
+}
+
+// listDependents adds what references the record id to deps.
+type listDependents func(tx storeTx, id uuid.UUID, deps dependents) error
+
+type conflictResponse struct {
+	errorResponse
+	Dependents dependents `json:"dependents"`
+}
+
+// cascadeParam parses ?cascade which defaults to false.
+func cascadeParam(ctx iris.Context) (bool, error) {
+	raw := ctx.URLParam("cascade")
+	if raw == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(raw)
+}
+
+// respondReferenced answers a deletion which failed as the record id is still referenced
+// with the conflict code and what list finds to reference it.
+func respondReferenced(ctx iris.Context, code string, id uuid.UUID, list listDependents) {
+	deps := dependents{}
+
+	errTx := doTx(true, func(tx storeTx) error {
+		return list(tx, id, deps)
+	})
+	if errTx != nil {
+		respondInternal(ctx, errTx)
+		return
+	}
+
+	for _, ids := range deps {
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i].String() < ids[j].String()
+		})
+	}
+
+	ae := apiError{code: code}
+
+	ctx.StatusCode(ae.status())
+	ctx.JSON(conflictResponse{ae.response(langPrefs(ctx)), deps})
+}
+
+func stateDependents(tx storeTx, state uuid.UUID, deps dependents) error {
+	offices, errOs := tx.offices(state, "", "")
+	if errOs != nil {
+		return errOs
+	}
+
+	for _, office := range offices {
+		deps.add("offices", office.Id)
+
+		if errOD := officeDependents(tx, office.Id, deps); errOD != nil {
+			return errOD
+		}
+	}
+
+	return nil
+}
+
+func officeDependents(tx storeTx, office uuid.UUID, deps dependents) error {
+	stations, errSs := tx.officeStations(office)
+	if errSs != nil {
+		return errSs
+	}
+
+	for _, station := range stations {
+		deps.add("stations", station.Id)
+	}
+
+	return nil
+}
+
+func districtDependents(tx storeTx, district uuid.UUID, deps dependents) error {
+	stations, errSs := tx.districtStations(district)
+	if errSs != nil {
+		return errSs
+	}
+
+	for _, station := range stations {
+		deps.add("stations", station.Id)
+	}
+
+	candidates, errCs := tx.candidates(district)
+	if errCs != nil {
+		return errCs
+	}
+
+	for _, candidate := range candidates {
+		deps.add("candidates", candidate.Id)
+	}
+
+	_, ok, errRe := tx.recommendation(district)
+	if errRe != nil {
+		return errRe
+	}
+
+	if ok {
+		deps.add("recommendations", district)
+	}
+
+	return nil
+}
+
+func candidateDependents(tx storeTx, candidate uuid.UUID, deps dependents) error {
+	c, ok, errCa := tx.candidate(candidate)
+	if errCa != nil || !ok {
+		return errCa
+	}
+
+	recommendation, ok, errRe := tx.recommendation(c.District)
+	if errRe != nil {
+		return errRe
+	}
+
+	if ok && recommendation.Candidate == candidate {
+		deps.add("recommendations", c.District)
+	}
+
+	return nil
+}
+
+func electionDependents(tx storeTx, election uuid.UUID, deps dependents) error {
+	districts, errDs := tx.districts(election)
+	if errDs != nil {
+		return errDs
+	}
+
+	for _, district := range districts {
+		deps.add("districts", district.Id)
+
+		if errDD := districtDependents(tx, district.Id, deps); errDD != nil {
+			return errDD
+		}
+	}
+
+	return nil
+}
+
+// deleteDependents deletes what list finds to reference the record id, referencing records first, as p's doing.
+// Recommendations are identified by their districts.
+// It fails with errNotGranted if p may not edit any of the offices and stations, see mayEdit.
+func deleteDependents(tx storeTx, p principal, id uuid.UUID, list listDependents) error {
+	deps := dependents{}
+	if errLD := list(tx, id, deps); errLD != nil {
+		return errLD
+	}
+
+	for _, district := range deps["recommendations"] {
+		errAu := audited(tx, p, "recommendation", district, func() error {
+			_, errDR := tx.deleteRecommendation(district)
+			return errDR
+		})
+		if errAu != nil {
+			return errAu
+		}
+	}
+
+	for _, station := range deps["stations"] {
+		if _, allowed, errME := mayEditStation(tx, p, station); errME != nil {
+			return errME
+		} else if !allowed {
+			return errNotGranted
+		}
+
+		errAu := audited(tx, p, "station", station, func() error {
+			_, errDS := tx.deleteStation(station)
+			return errDS
+		})
+		if errAu != nil {
+			return errAu
+		}
+	}
+
+	for _, office := range deps["offices"] {
+		if _, allowed, errME := mayEditOffice(tx, p, office); errME != nil {
+			return errME
+		} else if !allowed {
+			return errNotGranted
+		}
+
+		errAu := audited(tx, p, "office", office, func() error {
+			_, errDO := tx.deleteOffice(office)
+			return errDO
+		})
+		if errAu != nil {
+			return errAu
+		}
+	}
+
+	for _, candidate := range deps["candidates"] {
+		errAu := audited(tx, p, "candidate", candidate, func() error {
+			_, errDC := tx.deleteCandidate(candidate)
+			return errDC
+		})
+		if errAu != nil {
+			return errAu
+		}
+	}
+
+	return nil
+}
@@ -1,172 +1,405 @@
 package main
 
 import (
-	"database/sql"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
-	log "github.com/sirupsen/logrus"
 	"strings"
 )
 
 func putDistricts(ctx iris.Context) {
 	var payload struct {
-		RuName string `json:"ru_name"`
+		RuName string            `json:"ru_name"`
+		Names  map[string]string `json:"names"`
+	}
+
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
+		return
 	}
 
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
 		return
 	}
 
 	uid, errNR := uuid.NewRandom()
 	if errNR != nil {
-		ctx.StatusCode(500)
-		ctx.JSON(errorResponse{errNR.Error()})
+		respondInternal(ctx, errNR)
 		return
 	}
 
+	p := principalOf(ctx)
+	var found bool
+
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			_, errEx := tx.Exec(`INSERT INTO district(ext_id, ru_name) VALUES ($1, $2)`, uid, payload.RuName)
-			return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			return audited(tx, p, "district", uid, func() error {
+				if errPD := tx.putDistrict(districtRecord{uid, electionId, payload.RuName}); errPD != nil {
+					return errPD
+				}
+
+				return putNames(tx, uid, names)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	ctx.StatusCode(201)
-	ctx.JSON(struct {
-		Id uuid.UUID `json:"id"`
-	}{uid})
+	if found {
+		ctx.StatusCode(201)
+		ctx.JSON(struct {
+			Id uuid.UUID `json:"id"`
+		}{uid})
+	} else {
+		respondError(ctx, apiError{code: "not_found.election"})
+	}
 }
 
 func getDistricts(ctx iris.Context) {
-	type row struct {
-		ExtId  uuid.UUID
-		RuName string
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var found bool
+	var res map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			rows, errDs := tx.districts(electionId)
+			if errDs != nil {
+				return errDs
+			}
+
+			res = make(map[uuid.UUID]string, len(rows))
+
+			for _, row := range rows {
+				res[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, res)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.election"})
+	}
+}
+
+func getDistrict(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var district districtRecord
+	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errDs error
+			if district, found, errDs = tx.district(extId); errDs != nil || !found {
+				return errDs
+			}
+
+			if version, _, errDs = tx.version("district", extId); errDs != nil {
+				return errDs
+			}
+
+			name = map[uuid.UUID]string{district.Id: district.RuName}
+			return localize(tx, prefs, name)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			Id       uuid.UUID `json:"id"`
+			Election uuid.UUID `json:"election"`
+			RuName   string    `json:"ru_name"`
+			Name     string    `json:"name"`
+		}{district.Id, district.Election, district.RuName, name[district.Id]})
+	} else {
+		respondError(ctx, apiError{code: "not_found.district"})
+	}
+}
+
+// getStateDistricts lists the districts of an election which any station in a state votes in.
+func getStateDistricts(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
+		return
 	}
 
-	rawRows, errFA := fetchAll(db, row{}, "SELECT ext_id, ru_name FROM district")
-	if errFA != nil {
-		log.WithFields(log.Fields{"error": errFA.Error()}).Error("Query error")
-		ctx.StatusCode(500)
+	drafts, ok := draftsParam(ctx)
+	if !ok {
 		return
 	}
 
-	rows := rawRows.([]row)
-	res := make(map[uuid.UUID]string, len(rows))
+	prefs := langPrefs(ctx)
+	var foundElection, found bool
+	var res map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
+			}
+
+			if foundElection = ok; !foundElection {
+				return nil
+			}
+
+			_, ok, errSt := tx.state(extId)
+			if errSt != nil {
+				return errSt
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			offices, errOs := tx.offices(extId, "", "")
+			if errOs != nil {
+				return errOs
+			}
+
+			res = map[uuid.UUID]string{}
+
+			for _, office := range offices {
+				stations, errSs := tx.stations(office.Id, electionId)
+				if errSs != nil {
+					return errSs
+				}
 
-	for _, row := range rows {
-		res[row.ExtId] = row.RuName
+				for _, station := range stations {
+					if _, ok := res[station.District]; ok {
+						continue
+					}
+
+					district, ok, errDs := tx.district(station.District)
+					if errDs != nil {
+						return errDs
+					}
+
+					if ok {
+						res[district.Id] = district.RuName
+					}
+				}
+			}
+
+			return localize(tx, prefs, res)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
 	}
 
-	ctx.JSON(res)
+	if !foundElection {
+		respondError(ctx, apiError{code: "not_found.election"})
+	} else if found {
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.state"})
+	}
 }
 
 func postDistricts(ctx iris.Context) {
 	var payload struct {
-		RuName string `json:"ru_name"`
+		RuName string            `json:"ru_name"`
+		Names  map[string]string `json:"names"`
 	}
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
 		return
 	}
 
-	var found bool
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`UPDATE district SET ru_name=$1 WHERE ext_id=$2`, payload.RuName, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "district", extId, versions); errCV != nil || !current {
+				return errCV
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
-			}
+			return audited(tx, p, "district", extId, func() error {
+				ok, errUD := tx.updateDistrict(districtRecord{Id: extId, RuName: payload.RuName})
+				if errUD != nil {
+					return errUD
+				}
 
-			found = rows > 0
+				if found = ok; !found {
+					return nil
+				}
 
-			return nil
+				return putNames(tx, extId, names)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if found {
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
 		ctx.StatusCode(204)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such district"})
+		respondError(ctx, apiError{code: "not_found.district"})
 	}
 }
 
 func deleteDistricts(ctx iris.Context) {
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
-	var found bool
+	cascade, errCP := cascadeParam(ctx)
+	if errCP != nil {
+		respondError(ctx, apiError{code: "validation.cascade_invalid", field: "cascade"})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`DELETE FROM district WHERE ext_id=$1`, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "district", extId, versions); errCV != nil || !current {
+				return errCV
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			if cascade {
+				if errDp := deleteDependents(tx, p, extId, districtDependents); errDp != nil {
+					return errDp
+				}
 			}
 
-			found = rows > 0
-
-			return nil
+			return audited(tx, p, "district", extId, func() error {
+				var errDD error
+				found, errDD = tx.deleteDistrict(extId)
+				return errDD
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			if errTx == errNotGranted {
+				respondError(ctx, apiError{code: "forbidden.state"})
+			} else if referenced(errTx) {
+				respondReferenced(ctx, "conflict.district_referenced", extId, districtDependents)
+			} else {
+				respondInternal(ctx, errTx)
+			}
+
 			return
 		}
 	}
 
-	if found {
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
 		ctx.StatusCode(204)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such district"})
+		respondError(ctx, apiError{code: "not_found.district"})
 	}
 }
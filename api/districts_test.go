@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestDistrictsRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Empty()
+
+	id := create(admin, "/v1/districts", map[string]interface{}{
+		"ru_name": "Округ №1", "names": map[string]string{"en": "District 1"},
+	})
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Equal(map[string]string{id: "Округ №1"})
+
+	anon.GET("/v1/districts").WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{id: "District 1"})
+
+	admin.POST("/v1/districts/" + id).WithJSON(map[string]string{"ru_name": "Округ №2"}).Expect().Status(204)
+
+	// Leaving out .names keeps the translations.
+	anon.GET("/v1/districts").WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{id: "District 1"})
+
+	admin.POST("/v1/districts/" + id).WithJSON(map[string]interface{}{
+		"ru_name": "Округ №2", "names": map[string]string{},
+	}).Expect().Status(204)
+
+	anon.GET("/v1/districts").WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{id: "Округ №2"})
+
+	admin.DELETE("/v1/districts/" + id).Expect().Status(204)
+	admin.DELETE("/v1/districts/" + id).Expect().Status(404)
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestDistrictsNotFound(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	anon.GET("/v1/districts").Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.election")
+
+	admin.PUT("/v1/districts").WithJSON(map[string]string{"ru_name": "Округ №1"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.election")
+
+	missing := uuid.New().String()
+
+	anon.GET("/v1/elections/" + missing + "/districts").Expect().Status(404)
+
+	admin.PUT("/v1/elections/" + missing + "/districts").WithJSON(map[string]string{"ru_name": "Округ №1"}).
+		Expect().Status(404)
+
+	admin.POST("/v1/districts/"+missing).WithJSON(map[string]string{"ru_name": "Округ №1"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.district")
+}
+
+func TestDistrictsCascade(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	candidate := create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(204)
+	publishDrafts()
+
+	admin.DELETE("/v1/districts/"+district).Expect().Status(409).JSON().Object().
+		ValueEqual("dependents", map[string][]string{
+			"stations": {station}, "candidates": {candidate}, "recommendations": {district},
+		})
+
+	anon.GET("/v1/stations/" + station + "/recommendation").Expect().Status(200)
+
+	admin.DELETE("/v1/districts/"+district).WithQuery("cascade", "1").Expect().Status(204)
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Empty()
+	anon.GET("/v1/offices/" + office + "/stations").Expect().Status(200).JSON().Object().Empty()
+	admin.DELETE("/v1/candidates/" + candidate).Expect().Status(404)
+	admin.DELETE("/v1/offices/" + office).Expect().Status(204)
+}
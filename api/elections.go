@@ -0,0 +1,392 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var electionStatuses = map[string]struct{}{
+	"planned":  {},
+	"active":   {},
+	"finished": {},
+}
+
+var currentElection uuid.UUID
+
+func initElection() {
+	raw, ok := os.LookupEnv("VOTEAPI_ELECTION")
+	if !ok {
+		return
+	}
+
+	var errPU error
+	if currentElection, errPU = uuid.Parse(raw); errPU != nil {
+		log.WithFields(log.Fields{"var": "VOTEAPI_ELECTION", "error": errPU.Error()}).Fatal("Bad election ID")
+	}
+}
+
+func electionParam(ctx iris.Context) (uuid.UUID, error) {
+	raw := ctx.Params().Get("election")
+	if raw == "" {
+		return uuid.Nil, nil
+	}
+
+	return uuid.Parse(raw)
+}
+
+// findElection treats uuid.Nil as $VOTEAPI_ELECTION or, if unset, the election voting most recently.
+func findElection(tx storeTx, extId uuid.UUID) (uuid.UUID, bool, error) {
+	if extId == uuid.Nil {
+		extId = currentElection
+	}
+
+	var election electionRecord
+	var found bool
+	var errEl error
+
+	if extId == uuid.Nil {
+		election, found, errEl = tx.latestElection()
+	} else {
+		election, found, errEl = tx.election(extId)
+	}
+
+	return election.Id, found, errEl
+}
+
+type electionPayload struct {
+	RuName     string            `json:"ru_name"`
+	VotingFrom string            `json:"voting_from"`
+	VotingTo   string            `json:"voting_to"`
+	Status     string            `json:"status"`
+	Names      map[string]string `json:"names,omitempty"`
+
+	votingFrom, votingTo time.Time
+}
+
+func (ep *electionPayload) validate() *apiError {
+	if strings.TrimSpace(ep.RuName) == "" {
+		return &apiError{code: "validation.ru_name_missing", field: "ru_name"}
+	}
+
+	var errPT error
+	if ep.votingFrom, errPT = time.Parse(dateLayout, ep.VotingFrom); errPT != nil {
+		return &apiError{"validation.voting_from_invalid", "voting_from", errPT.Error()}
+	}
+
+	if ep.votingTo, errPT = time.Parse(dateLayout, ep.VotingTo); errPT != nil {
+		return &apiError{"validation.voting_to_invalid", "voting_to", errPT.Error()}
+	}
+
+	if ep.votingTo.Before(ep.votingFrom) {
+		return &apiError{code: "validation.voting_to_before_from", field: "voting_to"}
+	}
+
+	if _, ok := electionStatuses[ep.Status]; !ok {
+		return &apiError{code: "validation.status_invalid", field: "status"}
+	}
+
+	return nil
+}
+
+func putElections(ctx iris.Context) {
+	var payload electionPayload
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			return audited(tx, p, "election", uid, func() error {
+				errPE := tx.putElection(electionRecord{
+					uid, payload.RuName, payload.votingFrom, payload.votingTo, payload.Status,
+				})
+				if errPE != nil {
+					return errPE
+				}
+
+				return putNames(tx, uid, names)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	ctx.StatusCode(201)
+	ctx.JSON(struct {
+		Id uuid.UUID `json:"id"`
+	}{uid})
+}
+
+func getElections(ctx iris.Context) {
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var current uuid.UUID
+	var elections []electionRecord
+	var localized map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errFE error
+			if current, _, errFE = findElection(tx, uuid.Nil); errFE != nil {
+				return errFE
+			}
+
+			var errEl error
+			if elections, errEl = tx.elections(); errEl != nil {
+				return errEl
+			}
+
+			localized = make(map[uuid.UUID]string, len(elections))
+
+			for _, row := range elections {
+				localized[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, localized)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	type election struct {
+		electionPayload
+		Name    string `json:"name"`
+		Current bool   `json:"current"`
+	}
+
+	res := make(map[uuid.UUID]election, len(elections))
+
+	for _, row := range elections {
+		res[row.Id] = election{
+			electionPayload{
+				RuName:     row.RuName,
+				VotingFrom: row.VotingFrom.Format(dateLayout),
+				VotingTo:   row.VotingTo.Format(dateLayout),
+				Status:     row.Status,
+			},
+			localized[row.Id],
+			row.Id == current,
+		}
+	}
+
+	ctx.JSON(res)
+}
+
+func getElection(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var election electionRecord
+	var found bool
+	var current uuid.UUID
+	var version int64
+	var name map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errEl error
+			if election, found, errEl = tx.election(extId); errEl != nil || !found {
+				return errEl
+			}
+
+			if current, _, errEl = findElection(tx, uuid.Nil); errEl != nil {
+				return errEl
+			}
+
+			if version, _, errEl = tx.version("election", extId); errEl != nil {
+				return errEl
+			}
+
+			name = map[uuid.UUID]string{election.Id: election.RuName}
+			return localize(tx, prefs, name)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			Id uuid.UUID `json:"id"`
+			electionPayload
+			Name    string `json:"name"`
+			Current bool   `json:"current"`
+		}{
+			election.Id,
+			electionPayload{
+				RuName:     election.RuName,
+				VotingFrom: election.VotingFrom.Format(dateLayout),
+				VotingTo:   election.VotingTo.Format(dateLayout),
+				Status:     election.Status,
+			},
+			name[election.Id],
+			election.Id == current,
+		})
+	} else {
+		respondError(ctx, apiError{code: "not_found.election"})
+	}
+}
+
+func postElections(ctx iris.Context) {
+	var payload electionPayload
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "election", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "election", extId, func() error {
+				ok, errUE := tx.updateElection(electionRecord{
+					extId, payload.RuName, payload.votingFrom, payload.votingTo, payload.Status,
+				})
+				if errUE != nil {
+					return errUE
+				}
+
+				if found = ok; !found {
+					return nil
+				}
+
+				return putNames(tx, extId, names)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.election"})
+	}
+}
+
+func deleteElections(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "election", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "election", extId, func() error {
+				var errDE error
+				found, errDE = tx.deleteElection(extId)
+				return errDE
+			})
+		})
+		if errTx != nil {
+			if referenced(errTx) {
+				respondReferenced(ctx, "conflict.election_referenced", extId, electionDependents)
+			} else {
+				respondInternal(ctx, errTx)
+			}
+
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.election"})
+	}
+}
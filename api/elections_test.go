@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestElectionsRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	anon.GET("/v1/elections").Expect().Status(200).JSON().Object().Empty()
+
+	id := newTestElection(admin)
+
+	election := anon.GET("/v1/elections").Expect().Status(200).JSON().Object().Value(id).Object()
+	election.ValueEqual("voting_from", "2021-09-17").ValueEqual("voting_to", "2021-09-19")
+	election.ValueEqual("status", "active").ValueEqual("current", true)
+
+	admin.POST("/v1/elections/" + id).WithJSON(map[string]interface{}{
+		"ru_name": "Выборы в Государственную Думу", "voting_from": "2021-09-17", "voting_to": "2021-09-19",
+		"status": "finished", "names": map[string]string{"en": "State Duma election"},
+	}).Expect().Status(204)
+
+	election = anon.GET("/v1/elections").WithQuery("lang", "en").Expect().Status(200).JSON().Object().
+		Value(id).Object()
+
+	election.ValueEqual("ru_name", "Выборы в Государственную Думу").ValueEqual("name", "State Duma election")
+	election.ValueEqual("status", "finished")
+
+	admin.DELETE("/v1/elections/" + id).Expect().Status(204)
+	admin.DELETE("/v1/elections/" + id).Expect().Status(404)
+
+	anon.GET("/v1/elections").Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestElectionsValidation(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	for payload, code := range map[[4]string]string{
+		{"Выборы", "17.09.2021", "2021-09-19", "active"}: "validation.voting_from_invalid",
+		{"Выборы", "2021-09-17", "", "active"}:           "validation.voting_to_invalid",
+		{"Выборы", "2021-09-19", "2021-09-17", "active"}: "validation.voting_to_before_from",
+		{"Выборы", "2021-09-17", "2021-09-19", "rigged"}: "validation.status_invalid",
+	} {
+		admin.PUT("/v1/elections").WithJSON(map[string]string{
+			"ru_name": payload[0], "voting_from": payload[1], "voting_to": payload[2], "status": payload[3],
+		}).Expect().Status(400).JSON().Object().ValueEqual("code", code)
+	}
+
+	admin.POST("/v1/elections/"+uuid.New().String()).WithJSON(map[string]string{
+		"ru_name": "Выборы", "voting_from": "2021-09-17", "voting_to": "2021-09-19", "status": "active",
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.election")
+}
+
+func TestElectionsCurrent(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	older := newTestElection(admin)
+	newer := create(admin, "/v1/elections", map[string]string{
+		"ru_name": "Выборы Президента", "voting_from": "2024-03-15", "voting_to": "2024-03-17", "status": "planned",
+	})
+
+	elections := anon.GET("/v1/elections").Expect().Status(200).JSON().Object()
+	elections.Value(older).Object().ValueEqual("current", false)
+	elections.Value(newer).Object().ValueEqual("current", true)
+
+	district := create(admin, "/v1/elections/"+older+"/districts", map[string]string{"ru_name": "Округ №1"})
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Empty()
+	anon.GET("/v1/elections/" + older + "/districts").Expect().Status(200).JSON().Object().Keys().ContainsOnly(district)
+
+	currentElection = uuid.MustParse(older)
+
+	anon.GET("/v1/districts").Expect().Status(200).JSON().Object().Keys().ContainsOnly(district)
+	anon.GET("/v1/elections").Expect().Status(200).JSON().Object().Value(older).Object().ValueEqual("current", true)
+}
+
+func TestElectionsWithDistrictsCantBeDeleted(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	election := newTestElection(admin)
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+
+	conflict := admin.DELETE("/v1/elections/" + election).Expect().Status(409).JSON().Object()
+	conflict.ValueEqual("code", "conflict.election_referenced")
+	conflict.ValueEqual("dependents", map[string][]string{"districts": {district}})
+
+	admin.DELETE("/v1/districts/" + district).Expect().Status(204)
+	admin.DELETE("/v1/elections/" + election).Expect().Status(204)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+type errorResponse struct {
+	// Code is stable, e.g. "not_found.state". The part before the dot determines the HTTP status.
+	Code string `json:"code"`
+	// Field is the offending request field, if any, e.g. "names.en".
+	Field string `json:"field,omitempty"`
+	// Message is for humans and localized.
+	Message string `json:"message"`
+	// Error is for humans in English and may carry details.
+	Error string `json:"error"`
+	// CorrelationId identifies the log entry of an internal error.
+	CorrelationId *uuid.UUID `json:"correlation_id,omitempty"`
+}
+
+// apiError is a client error, see errorResponse.
+type apiError struct {
+	code   string
+	field  string
+	detail string
+}
+
+type errorMessage struct {
+	en, ru string
+}
+
+var errorStatuses = map[string]int{
+	"validation":          400,
+	"unauthorized":        401,
+	"forbidden":           403,
+	"not_found":           404,
+	"conflict":            409,
+	"precondition_failed": 412,
+	"internal":            500,
+}
+
+var errorMessages = map[string]errorMessage{
+	"validation.malformed_id":     {"Malformed ID", "Некорректный идентификатор"},
+	"validation.malformed_json":   {"Malformed JSON", "Некорректный JSON"},
+	"validation.ru_name_missing":  {"Russian name missing", "Не указано название на русском"},
+	"validation.language_invalid": {"Invalid language tag", "Некорректный код языка"},
+	"validation.name_redundant":   {"Russian name belongs into .ru_name", "Название на русском указывается в .ru_name"},
+	"validation.name_missing":     {"Name missing", "Не указано название"},
+	"validation.iso_code_invalid": {"Unknown ISO country code", "Неизвестный код страны ISO"},
+	"validation.location_incomplete": {
+		"Latitude and longitude must be given together", "Широта и долгота указываются вместе",
+	},
+	"validation.lat_invalid":           {"Latitude invalid", "Некорректная широта"},
+	"validation.lon_invalid":           {"Longitude invalid", "Некорректная долгота"},
+	"validation.address_missing":       {"Address missing", "Не указан адрес"},
+	"validation.subdivision_invalid":   {"Invalid ISO 3166-2 subdivision code", "Некорректный код региона ISO 3166-2"},
+	"validation.city_missing":          {"City name missing", "Не указано название города"},
+	"validation.limit_out_of_range":    {"Limit out of range", "Лимит вне допустимого диапазона"},
+	"validation.cascade_invalid":       {"Cascade flag invalid", "Некорректный параметр cascade"},
+	"validation.district_missing":      {"District missing", "Не указан округ"},
+	"validation.party_missing":         {"Party missing", "Не указана партия"},
+	"validation.status_invalid":        {"Status invalid", "Некорректный статус"},
+	"validation.candidate_missing":     {"Candidate missing", "Не указан кандидат"},
+	"validation.voting_from_invalid":   {"Voting start date invalid", "Некорректная дата начала голосования"},
+	"validation.voting_to_invalid":     {"Voting end date invalid", "Некорректная дата окончания голосования"},
+	"validation.voting_to_before_from": {"Voting ends before it starts", "Голосование кончается раньше начала"},
+	"not_found.state":                  {"No such state", "Нет такого государства"},
+	"not_found.office":                 {"No such office", "Нет такого представительства"},
+	"not_found.station":                {"No such polling station", "Нет такого избирательного участка"},
+	"not_found.district":               {"No such district", "Нет такого округа"},
+	"not_found.candidate":              {"No such candidate", "Нет такого кандидата"},
+	"not_found.candidate_in_district":  {"No such candidate in this district", "В этом округе нет такого кандидата"},
+	"not_found.recommendation":         {"No recommendation (yet)", "Рекомендации (пока) нет"},
+	"not_found.election":               {"No such election", "Нет таких выборов"},
+	"conflict.iso_code_taken":          {"ISO code already taken", "Код ISO уже занят"},
+	"conflict.state_referenced":        {"State still has offices", "У государства остались представительства"},
+	"conflict.office_referenced":       {"Office still has polling stations", "У представительства остались участки"},
+	"conflict.district_referenced": {
+		"District still has polling stations, candidates or a recommendation",
+		"У округа остались участки, кандидаты или рекомендация",
+	},
+	"conflict.candidate_referenced":   {"Candidate still recommended", "Кандидат всё ещё рекомендован"},
+	"conflict.election_referenced":    {"Election still has districts", "У выборов остались округа"},
+	"validation.account_name_missing": {"Account name missing", "Не указано имя учётной записи"},
+	"validation.password_missing":     {"Password missing", "Не указан пароль"},
+	"validation.password_too_short":   {"Password shorter than 8 characters", "Пароль короче 8 символов"},
+	"validation.role_invalid":         {"Role invalid", "Некорректная роль"},
+	"not_found.account":               {"No such account", "Нет такой учётной записи"},
+	"conflict.account_name_taken":     {"Account name already taken", "Имя учётной записи уже занято"},
+	"validation.token_name_missing":   {"Token name missing", "Не указано имя токена"},
+	"validation.scopes_missing":       {"Scopes missing", "Не указаны области доступа"},
+	"validation.scope_invalid":        {"Scope invalid", "Некорректная область доступа"},
+	"validation.expires_invalid":      {"Expiry not in the future", "Срок действия уже истёк"},
+	"validation.revision_invalid":     {"Revision invalid", "Некорректная ревизия"},
+	"validation.since_invalid":        {"Timestamp invalid", "Некорректная метка времени"},
+	"validation.include_invalid":      {"Only drafts may be included", "Включить можно только черновики"},
+	"not_found.token":                 {"No such token", "Нет такого токена"},
+	"conflict.parent_deleted":         {"Restore what this refers to first", "Сначала восстановите то, на что это ссылается"},
+	"unauthorized":                    {"Authentication required", "Эх, чекисты! Пошли бы вы далеко и надолго."},
+	"forbidden.state":                 {"State not granted to you", "Это государство вам не доверено"},
+	"forbidden.role":                  {"Your role doesn't allow this", "Ваша роль этого не позволяет"},
+	"forbidden.scope":                 {"Your token's scopes don't allow this", "Область доступа вашего токена этого не позволяет"},
+	"validation.if_match_invalid":     {"If-Match must only carry ETags of single-resource reads", "If-Match должен содержать только ETag отдельных записей"},
+	"precondition_failed.version":     {"The record has been changed meanwhile", "Запись была изменена в это время"},
+	"internal":                        {"Internal error", "Внутренняя ошибка"},
+}
+
+func (ae apiError) status() int {
+	return errorStatuses[strings.SplitN(ae.code, ".", 2)[0]]
+}
+
+func (ae apiError) response(prefs []string) errorResponse {
+	msg, ok := errorMessages[ae.code]
+	if !ok {
+		msg = errorMessage{ae.code, ae.code}
+	}
+
+	res := errorResponse{Code: ae.code, Field: ae.field, Message: msg.ru, Error: msg.en}
+
+	for _, lang := range prefs {
+		if lang == "en" {
+			res.Message = msg.en
+			break
+		}
+	}
+
+	if ae.detail != "" {
+		res.Error += ": " + ae.detail
+	}
+
+	return res
+}
+
+func respondError(ctx iris.Context, ae apiError) {
+	ctx.StatusCode(ae.status())
+	ctx.JSON(ae.response(langPrefs(ctx)))
+}
+
+// respondInternal logs err and answers with just a reference to the log entry not to leak any details.
+func respondInternal(ctx iris.Context, err error) {
+	// Without an ID the log entry is just harder to find.
+	id, _ := uuid.NewRandom()
+	log.WithFields(log.Fields{"correlation_id": id.String(), "error": err.Error()}).Error("Internal error")
+
+	res := apiError{code: "internal"}.response(langPrefs(ctx))
+	res.CorrelationId = &id
+
+	ctx.StatusCode(500)
+	ctx.JSON(res)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type failingStore struct{}
+
+func (failingStore) tx(bool, func(tx storeTx) error) error {
+	return errors.New(`pq: relation "state" does not exist`)
+}
+
+func TestErrorCodesHaveStatuses(t *testing.T) {
+	for code := range errorMessages {
+		if (apiError{code: code}).status() == 0 {
+			t.Errorf("%s has no HTTP status", code)
+		}
+	}
+}
+
+func TestErrorFieldsAndMessages(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	invalid := admin.PUT("/v1/states").WithJSON(map[string]interface{}{
+		"ru_name": "Германия", "names": map[string]string{"xx-invalid-tag": "Germany"},
+	}).Expect().Status(400).JSON().Object()
+
+	invalid.ValueEqual("code", "validation.language_invalid").ValueEqual("field", "names.xx-invalid-tag")
+	invalid.ValueEqual("message", "Некорректный код языка").ValueEqual("error", "Invalid language tag")
+
+	notFound := anon.GET("/v1/states/by-code/DE").WithHeader("Accept-Language", "de, en;q=0.5").
+		Expect().Status(404).JSON().Object()
+
+	notFound.ValueEqual("code", "not_found.state").ValueEqual("message", "No such state").NotContainsKey("field")
+
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 0).Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.lon_invalid").ValueEqual("field", "lon")
+
+	admin.POST("/v1/states/x").WithJSON(map[string]string{"ru_name": "Германия"}).Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.malformed_id").Value("error").String().Contains("invalid UUID length")
+}
+
+func TestInternalErrorsDontLeak(t *testing.T) {
+	anon, _ := newTestApi(t)
+	backend = failingStore{}
+
+	internal := anon.GET("/v1/states").Expect().Status(500).JSON().Object()
+
+	internal.ValueEqual("code", "internal").ValueEqual("error", "Internal error")
+	internal.Value("correlation_id").String().Length().Equal(36)
+	internal.Value("error").String().NotContains("relation")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0088
+
+type location struct {
+	Lat     *float64 `json:"lat"`
+	Lon     *float64 `json:"lon"`
+	Address *string  `json:"address"`
+}
+
+// givenLocation tells which location fields an update payload has at all, even if null.
+type givenLocation struct {
+	lat, lon, address bool
+}
+
+// newGivenLocation inspects the JSON object body, see givenLocation.
+func newGivenLocation(body []byte) givenLocation {
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+
+	_, lat := fields["lat"]
+	_, lon := fields["lon"]
+	_, address := fields["address"]
+
+	return givenLocation{lat, lon, address}
+}
+
+// validate demands lat and lon to be given together, even if null, not to keep only one of them.
+func (g givenLocation) validate() *apiError {
+	if !g.lat && g.lon {
+		return &apiError{code: "validation.location_incomplete", field: "lat"}
+	}
+
+	if g.lat && !g.lon {
+		return &apiError{code: "validation.location_incomplete", field: "lon"}
+	}
+
+	return nil
+}
+
+// keep takes over the fields of old not given in an update, null clears them.
+func (l *location) keep(old location, given givenLocation) {
+	if !given.lat {
+		l.Lat, l.Lon = old.Lat, old.Lon
+	}
+
+	if !given.address {
+		l.Address = old.Address
+	}
+}
+
+func (l *location) validate() *apiError {
+	if l.Lat == nil && l.Lon != nil {
+		return &apiError{code: "validation.location_incomplete", field: "lat"}
+	}
+
+	if l.Lat != nil && l.Lon == nil {
+		return &apiError{code: "validation.location_incomplete", field: "lon"}
+	}
+
+	if l.Lat != nil && !(*l.Lat >= -90 && *l.Lat <= 90) {
+		return &apiError{code: "validation.lat_invalid", field: "lat"}
+	}
+
+	if l.Lon != nil && !(*l.Lon >= -180 && *l.Lon <= 180) {
+		return &apiError{code: "validation.lon_invalid", field: "lon"}
+	}
+
+	if l.Address != nil && strings.TrimSpace(*l.Address) == "" {
+		return &apiError{code: "validation.address_missing", field: "address"}
+	}
+
+	return nil
+}
+
+// greatCircle returns the distance between two points in kilometers using the haversine formula.
+func greatCircle(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"github.com/google/uuid"
+)
+
+// errNotGranted fails a cascading deletion which would delete offices or stations of a state not granted.
+var errNotGranted = errors.New("state not granted")
+
+// mayEdit tells whether p may edit the offices and stations of state.
+// Admins may edit all of them, everyone else only those of the states granted to them.
+func mayEdit(tx storeTx, p principal, state uuid.UUID) (bool, error) {
+	if p.Role == "admin" {
+		return true, nil
+	}
+
+	states, errGr := tx.grants(p.Account)
+	if errGr != nil {
+		return false, errGr
+	}
+
+	for _, granted := range states {
+		if granted == state {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// mayEditOffice resolves the state of office and tells whether p may edit the office.
+func mayEditOffice(tx storeTx, p principal, office uuid.UUID) (found, allowed bool, err error) {
+	o, ok, errOf := tx.office(office)
+	if errOf != nil || !ok {
+		return false, false, errOf
+	}
+
+	allowed, err = mayEdit(tx, p, o.State)
+	return true, allowed, err
+}
+
+// mayEditStation resolves the state of station and tells whether p may edit the station.
+func mayEditStation(tx storeTx, p principal, station uuid.UUID) (found, allowed bool, err error) {
+	s, ok, errSt := tx.station(station)
+	if errSt != nil || !ok {
+		return false, false, errSt
+	}
+
+	return mayEditOffice(tx, p, s.Office)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestGrantsScopeEditors(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	germany := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	austria := create(admin, "/v1/states", map[string]string{"ru_name": "Австрия"})
+	vienna := create(admin, "/v1/states/"+austria+"/offices", map[string]string{"ru_name": "Посольство в Вене"})
+	station := create(admin, "/v1/offices/"+vienna+"/stations", map[string]string{
+		"ru_name": "УИК №8101", "district": district,
+	})
+
+	id, editor := newTestAccount(anon, admin, "coordinator", "editor")
+
+	// Editors without grants may edit no offices and stations at all.
+	editor.PUT("/v1/states/"+germany+"/offices").WithJSON(map[string]string{"ru_name": "Посольство в Берлине"}).
+		Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.state")
+
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]interface{}{
+		"name": "coordinator", "role": "editor", "states": []string{germany},
+	}).Expect().Status(204)
+
+	admin.GET("/v1/accounts").Expect().Status(200).JSON().Object().Value(id).Object().
+		Value("states").Array().Elements(germany)
+
+	berlin := create(editor, "/v1/states/"+germany+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	own := create(editor, "/v1/offices/"+berlin+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	editor.POST("/v1/stations/" + own).WithJSON(map[string]string{"ru_name": "УИК №8002", "district": district}).
+		Expect().Status(204)
+
+	editor.PUT("/v1/states/" + austria + "/offices").WithJSON(map[string]string{"ru_name": "Консульство"}).
+		Expect().Status(403)
+
+	editor.POST("/v1/offices/" + vienna).WithJSON(map[string]string{"ru_name": "Посольство"}).Expect().Status(403)
+	editor.DELETE("/v1/offices/"+vienna).WithQuery("cascade", "true").Expect().Status(403)
+
+	editor.PUT("/v1/offices/" + vienna + "/stations").WithJSON(map[string]string{
+		"ru_name": "УИК №8102", "district": district,
+	}).Expect().Status(403)
+
+	editor.POST("/v1/stations/" + station).WithJSON(map[string]string{"ru_name": "УИК", "district": district}).
+		Expect().Status(403)
+
+	editor.DELETE("/v1/stations/" + station).Expect().Status(403)
+
+	anon.GET("/v1/offices/" + vienna + "/stations").Expect().Status(200).JSON().Object().Keys().ContainsOnly(station)
+
+	// Missing targets are still reported as such.
+	editor.DELETE("/v1/stations/" + uuid.New().String()).Expect().Status(404)
+
+	editor.DELETE("/v1/stations/" + own).Expect().Status(204)
+	editor.DELETE("/v1/offices/" + berlin).Expect().Status(204)
+}
+
+func TestGrantsScopeCascades(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	germany := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	austria := create(admin, "/v1/states", map[string]string{"ru_name": "Австрия"})
+	berlin := create(admin, "/v1/states/"+germany+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	vienna := create(admin, "/v1/states/"+austria+"/offices", map[string]string{"ru_name": "Посольство в Вене"})
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+
+	own := create(admin, "/v1/offices/"+berlin+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	station := create(admin, "/v1/offices/"+vienna+"/stations", map[string]string{
+		"ru_name": "УИК №8101", "district": district,
+	})
+
+	id, editor := newTestAccount(anon, admin, "coordinator", "editor")
+
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]interface{}{
+		"name": "coordinator", "role": "editor", "states": []string{germany},
+	}).Expect().Status(204)
+
+	// Cascades don't reach what the editor may not delete directly.
+	editor.DELETE("/v1/states/"+austria).WithQuery("cascade", "true").Expect().Status(403).JSON().Object().
+		ValueEqual("code", "forbidden.state")
+	editor.DELETE("/v1/districts/"+district).WithQuery("cascade", "true").Expect().Status(403)
+
+	anon.GET("/v1/offices/" + vienna + "/stations").Expect().Status(200).JSON().Object().Keys().ContainsOnly(station)
+	anon.GET("/v1/offices/" + berlin + "/stations").Expect().Status(200).JSON().Object().Keys().ContainsOnly(own)
+
+	editor.DELETE("/v1/states/"+germany).WithQuery("cascade", "true").Expect().Status(204)
+	anon.GET("/v1/offices/" + berlin).Expect().Status(404)
+}
+
+func TestGrantsValidation(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	id, editor := newTestAccount(anon, admin, "coordinator", "editor")
+
+	admin.PUT("/v1/accounts").WithJSON(map[string]interface{}{
+		"name": "other", "password": "12345678", "role": "editor", "states": []string{uuid.New().String()},
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.state").ValueEqual("field", "states")
+
+	admin.GET("/v1/accounts").Expect().Status(200).JSON().Object().Keys().ContainsOnly(id)
+
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]interface{}{
+		"name": "coordinator", "role": "editor", "states": []string{state},
+	}).Expect().Status(204)
+
+	// Leaving out the states keeps them, deleting a state revokes its grants.
+	admin.POST("/v1/accounts/" + id).WithJSON(map[string]string{"name": "coordinator", "role": "editor"}).
+		Expect().Status(204)
+
+	create(editor, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", "true").Expect().Status(204)
+
+	admin.GET("/v1/accounts").Expect().Status(200).JSON().Object().Value(id).Object().
+		Value("states").Array().Empty()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"regexp"
+	"strings"
+)
+
+var subdivisionCode = regexp.MustCompile(`\A[A-Z]{2}-[A-Z0-9]{1,3}\z`)
+
+type jurisdiction struct {
+	Subdivisions []string `json:"subdivisions"`
+	Cities       []string `json:"cities"`
+}
+
+func (j *jurisdiction) validate() *apiError {
+	if j == nil {
+		return nil
+	}
+
+	for i, subdivision := range j.Subdivisions {
+		j.Subdivisions[i] = strings.ToUpper(strings.TrimSpace(subdivision))
+
+		if !subdivisionCode.MatchString(j.Subdivisions[i]) {
+			return &apiError{code: "validation.subdivision_invalid", field: "jurisdiction.subdivisions"}
+		}
+	}
+
+	for i, city := range j.Cities {
+		if j.Cities[i] = strings.TrimSpace(city); j.Cities[i] == "" {
+			return &apiError{code: "validation.city_missing", field: "jurisdiction.cities"}
+		}
+	}
+
+	return nil
+}
+
+// putJurisdiction replaces the jurisdiction of office unless j is nil.
+func putJurisdiction(tx storeTx, office uuid.UUID, j *jurisdiction) error {
+	if j == nil {
+		return nil
+	}
+
+	return tx.replaceJurisdiction(office, *j)
+}
+
+func getJurisdiction(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	var found bool
+	var res jurisdiction
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			_, ok, errOf := tx.office(extId)
+			if errOf != nil {
+				return errOf
+			}
+
+			if found = ok; !found {
+				return nil
+			}
+
+			var errJd error
+			res, errJd = tx.jurisdiction(extId)
+			return errJd
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.office"})
+	}
+}
@@ -12,10 +12,6 @@ import (
 	"syscall"
 )
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
 var onTerm struct {
 	sync.RWMutex
 
@@ -24,28 +20,33 @@ var onTerm struct {
 
 func main() {
 	initLogging()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		initDb()
+
+		if db == nil {
+			log.Fatal("Only PostgreSQL databases can be migrated")
+		}
+
+		migrateCmd(os.Args[2:])
+		return
+	}
+
 	initAdmin()
+	initElection()
+	initTrash()
 	initDb()
-	go wait4term()
 
-	app := iris.Default()
+	if db != nil {
+		if errMU := withMigrationLock(migrateUp); errMU != nil {
+			log.WithFields(log.Fields{"error": errMU.Error()}).Fatal("Couldn't migrate database schema")
+		}
+	}
+
+	go wait4term()
+	go purgeTrash()
 
-	app.Put("/v1/states", mustBeAdmin, ensureSchema, putStates)
-	app.Get("/v1/states", ensureSchema, getStates)
-	app.Post("/v1/states/{ext_id:string}", mustBeAdmin, ensureSchema, postStates)
-	app.Delete("/v1/states/{ext_id:string}", mustBeAdmin, ensureSchema, deleteStates)
-	app.Put("/v1/states/{ext_id:string}/offices", mustBeAdmin, ensureSchema, putOffices)
-	app.Get("/v1/states/{ext_id:string}/offices", ensureSchema, getOffices)
-	app.Post("/v1/offices/{ext_id:string}", mustBeAdmin, ensureSchema, postOffices)
-	app.Delete("/v1/offices/{ext_id:string}", mustBeAdmin, ensureSchema, deleteOffices)
-	app.Put("/v1/offices/{ext_id:string}/stations", mustBeAdmin, ensureSchema, putStations)
-	app.Get("/v1/offices/{ext_id:string}/stations", ensureSchema, getStations)
-	app.Post("/v1/stations/{ext_id:string}", mustBeAdmin, ensureSchema, postStations)
-	app.Delete("/v1/stations/{ext_id:string}", mustBeAdmin, ensureSchema, deleteStations)
-	app.Put("/v1/districts", mustBeAdmin, ensureSchema, putDistricts)
-	app.Get("/v1/districts", ensureSchema, getDistricts)
-	app.Post("/v1/districts/{ext_id:string}", mustBeAdmin, ensureSchema, postDistricts)
-	app.Delete("/v1/districts/{ext_id:string}", mustBeAdmin, ensureSchema, deleteDistricts)
+	app := newApp()
 
 	onTerm.Lock()
 	onTerm.ToDo = append(onTerm.ToDo, func() {
@@ -56,6 +57,91 @@ func main() {
 	_ = app.Run(iris.Addr("[::]:8080"), iris.WithoutStartupLog, iris.WithoutInterruptHandler)
 }
 
+// newApp registers all routes.
+func newApp() *iris.Application {
+	app := iris.Default()
+	statesW := mustHaveRole("editor", "states:write")
+	officesW := mustHaveRole("editor", "offices:write")
+	stationsW := mustHaveRole("editor", "stations:write")
+	districtsW := mustHaveRole("editor", "districts:write")
+	candidatesW := mustHaveRole("editor", "candidates:write")
+	recommendationsW := mustHaveRole("editor", "recommendations:write")
+	electionsW := mustHaveRole("editor", "elections:write")
+	accountsR := mustHaveRole("admin", "accounts:read")
+	accountsW := mustHaveRole("admin", "accounts:write")
+	auditR := mustHaveRole("admin", "audit:read")
+	trashR := mustHaveRole("admin", "trash:read")
+	trashW := mustHaveRole("admin", "trash:write")
+	publishW := mustHaveRole("admin", "publish:write")
+	viewer := mustHaveRole("viewer", "")
+
+	app.Put("/v1/states", statesW, putStates)
+	app.Get("/v1/states", revisioned, getStates)
+	app.Get("/v1/states/{ext_id:string}", getState)
+	app.Post("/v1/states/{ext_id:string}", statesW, postStates)
+	app.Delete("/v1/states/{ext_id:string}", statesW, deleteStates)
+	app.Get("/v1/states/by-code/{cc:string}", getStateByCode)
+	app.Put("/v1/states/{ext_id:string}/offices", officesW, putOffices)
+	app.Get("/v1/states/{ext_id:string}/offices", revisioned, getOffices)
+	app.Get("/v1/states/{ext_id:string}/districts", revisioned, getStateDistricts)
+	app.Get("/v1/offices/nearest", revisioned, getNearestOffices)
+	app.Get("/v1/offices/{ext_id:string}", getOffice)
+	app.Post("/v1/offices/{ext_id:string}", officesW, postOffices)
+	app.Delete("/v1/offices/{ext_id:string}", officesW, deleteOffices)
+	app.Get("/v1/offices/{ext_id:string}/jurisdiction", getJurisdiction)
+	app.Put("/v1/offices/{ext_id:string}/stations", stationsW, putStations)
+	app.Get("/v1/offices/{ext_id:string}/stations", revisioned, getStations)
+	app.Get("/v1/offices/{ext_id:string}/recommendations", revisioned, getOfficeRecommendations)
+	app.Get("/v1/stations/{ext_id:string}", getStation)
+	app.Post("/v1/stations/{ext_id:string}", stationsW, postStations)
+	app.Delete("/v1/stations/{ext_id:string}", stationsW, deleteStations)
+	app.Get("/v1/stations/{ext_id:string}/recommendation", getStationRecommendation)
+	app.Get("/v1/tree", revisioned, getTree)
+	app.Get("/v1/changes", revisioned, getChanges)
+	app.Put("/v1/districts", districtsW, putDistricts)
+	app.Get("/v1/districts", revisioned, getDistricts)
+	app.Get("/v1/districts/{ext_id:string}", getDistrict)
+	app.Post("/v1/districts/{ext_id:string}", districtsW, postDistricts)
+	app.Delete("/v1/districts/{ext_id:string}", districtsW, deleteDistricts)
+	app.Put("/v1/districts/{ext_id:string}/candidates", candidatesW, putCandidates)
+	app.Get("/v1/districts/{ext_id:string}/candidates", revisioned, getCandidates)
+	app.Get("/v1/districts/{ext_id:string}/stations", revisioned, getDistrictStations)
+	app.Put("/v1/districts/{ext_id:string}/recommendation", recommendationsW, putRecommendation)
+	app.Delete("/v1/districts/{ext_id:string}/recommendation", recommendationsW, deleteRecommendation)
+	app.Put("/v1/elections", electionsW, putElections)
+	app.Get("/v1/elections", revisioned, getElections)
+	app.Get("/v1/elections/{ext_id:string}", getElection)
+	app.Post("/v1/elections/{ext_id:string}", electionsW, postElections)
+	app.Delete("/v1/elections/{ext_id:string}", electionsW, deleteElections)
+	app.Put("/v1/elections/{election:string}/districts", districtsW, putDistricts)
+	app.Get("/v1/elections/{election:string}/districts", revisioned, getDistricts)
+	app.Get("/v1/elections/{election:string}/offices/{ext_id:string}/stations", revisioned, getStations)
+	app.Get("/v1/elections/{election:string}/offices/{ext_id:string}/recommendations", revisioned, getOfficeRecommendations)
+	app.Get("/v1/elections/{election:string}/states/{ext_id:string}/districts", revisioned, getStateDistricts)
+	app.Get("/v1/elections/{election:string}/tree", revisioned, getTree)
+	app.Get("/v1/candidates/{ext_id:string}", getCandidate)
+	app.Post("/v1/candidates/{ext_id:string}", candidatesW, postCandidates)
+	app.Delete("/v1/candidates/{ext_id:string}", candidatesW, deleteCandidates)
+	app.Put("/v1/accounts", accountsW, putAccounts)
+	app.Get("/v1/accounts", accountsR, getAccounts)
+	app.Get("/v1/accounts/{ext_id:string}", accountsR, getAccount)
+	app.Post("/v1/accounts/{ext_id:string}", accountsW, postAccounts)
+	app.Delete("/v1/accounts/{ext_id:string}", accountsW, deleteAccounts)
+	app.Get("/v1/audit", auditR, getAudit)
+	app.Get("/v1/trash", trashR, getTrash)
+	app.Post("/v1/publish", publishW, postPublish)
+
+	for _, kind := range trashKinds {
+		app.Post("/v1/"+kind+"s/{ext_id:string}/restore", trashW, restoreTrashed(kind))
+	}
+
+	app.Put("/v1/tokens", viewer, putTokens)
+	app.Get("/v1/tokens", viewer, getTokens)
+	app.Delete("/v1/tokens/{ext_id:string}", viewer, deleteTokens)
+
+	return app
+}
+
 func initLogging() {
 	if !terminal.IsTerminal(int(os.Stdout.Fd())) {
 		log.SetFormatter(&log.JSONFormatter{})
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"github.com/gavv/httpexpect"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12/httptest"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAdminName     = "admin"
+	testAdminPassword = "secret"
+)
+
+// newTestApi boots the app on a fresh in-memory store without listening anywhere.
+// It returns clients for anonymous and admin requests.
+func newTestApi(t *testing.T) (anon, admin *httpexpect.Expect) {
+	t.Helper()
+
+	backend = newMemStore()
+	currentElection = uuid.Nil
+
+	hash, errGF := bcrypt.GenerateFromPassword([]byte(testAdminName+":"+testAdminPassword), bcrypt.MinCost)
+	if errGF != nil {
+		t.Fatal(errGF)
+	}
+
+	adminName, adminHash = testAdminName, hash
+	bcryptCost = bcrypt.MinCost
+
+	anon = httptest.New(t, newApp())
+	admin = anon.Builder(func(req *httpexpect.Request) {
+		req.WithBasicAuth(testAdminName, testAdminPassword)
+	})
+
+	return
+}
+
+// create PUTs payload to path, publishes it and returns the ID of the created entity.
+func create(admin *httpexpect.Expect, path string, payload interface{}) string {
+	id := admin.PUT(path).WithJSON(payload).Expect().Status(201).JSON().Object().Value("id").String().Raw()
+	publishDrafts()
+
+	return id
+}
+
+// publishDrafts makes all drafts public right away, bypassing the API and hence the audit log.
+func publishDrafts() {
+	errTx := doTx(false, func(tx storeTx) error {
+		published, errPb := tx.publish(time.Now())
+		if errPb != nil || len(published) < 1 {
+			return errPb
+		}
+
+		return tx.bumpRevision()
+	})
+	if errTx != nil {
+		panic(errTx)
+	}
+}
+
+func newTestElection(admin *httpexpect.Expect) string {
+	return create(admin, "/v1/elections", map[string]interface{}{
+		"ru_name": "Выборы депутатов Государственной Думы", "voting_from": "2021-09-17", "voting_to": "2021-09-19",
+		"status": "active",
+	})
+}
+
+func TestAdminRoutesRequireAuth(t *testing.T) {
+	anon, _ := newTestApi(t)
+	id := uuid.New().String()
+	var checked int
+
+	for _, route := range newApp().GetRoutes() {
+		if route.Method == http.MethodGet {
+			continue
+		}
+
+		path := strings.ReplaceAll(route.FormattedPath, "%v", id)
+		checked++
+
+		anon.Request(route.Method, path).WithJSON(map[string]string{"ru_name": "x"}).
+			Expect().Status(401).JSON().Object().ValueEqual("code", "unauthorized")
+
+		anon.Request(route.Method, path).WithBasicAuth(testAdminName, "wrong").
+			WithJSON(map[string]string{"ru_name": "x"}).Expect().Status(401)
+
+		anon.Request(route.Method, path).WithBasicAuth("nobody", testAdminPassword).Expect().Status(401)
+		anon.Request(route.Method, path).WithBasicAuth("", "").Expect().Status(401)
+	}
+
+	if checked < 1 {
+		t.Error("no admin routes found")
+	}
+}
+
+func TestMalformedIds(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	for _, path := range []string{
+		"/v1/states/x/offices", "/v1/offices/x/jurisdiction", "/v1/offices/x/stations", "/v1/offices/x/recommendations",
+		"/v1/stations/x/recommendation", "/v1/districts/x/candidates", "/v1/elections/x/districts",
+	} {
+		anon.GET(path).Expect().Status(400).JSON().Object().ContainsKey("code")
+	}
+
+	for _, path := range []string{
+		"/v1/states/x", "/v1/offices/x", "/v1/stations/x", "/v1/districts/x", "/v1/elections/x", "/v1/candidates/x",
+	} {
+		admin.POST(path).WithJSON(map[string]string{"ru_name": "x"}).Expect().Status(400)
+		admin.DELETE(path).Expect().Status(400)
+	}
+}
+
+func TestMalformedPayloads(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	for _, path := range []string{"/v1/states", "/v1/districts", "/v1/elections"} {
+		admin.PUT(path).WithText("{").WithHeader("Content-Type", "application/json").Expect().Status(400)
+		admin.PUT(path).WithJSON(map[string]string{"ru_name": " "}).Expect().Status(400).
+			JSON().Object().ContainsKey("code")
+	}
+}
@@ -0,0 +1,1343 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memStore keeps everything in memory, e.g. for tests and offline demos. Transactions are serialized by a lock
+// and work on a copy of the data which replaces the original on success.
+type memStore struct {
+	sync.RWMutex
+
+	data *memData
+}
+
+var _ store = (*memStore)(nil)
+
+func newMemStore() *memStore {
+	return &memStore{data: &memData{
+		states:          map[uuid.UUID]stateRecord{},
+		offices:         map[uuid.UUID]officeRecord{},
+		jurisdictions:   map[uuid.UUID]jurisdiction{},
+		stations:        map[uuid.UUID]stationRecord{},
+		districts:       map[uuid.UUID]districtRecord{},
+		candidates:      map[uuid.UUID]candidateRecord{},
+		recommendations: map[uuid.UUID]recommendationRecord{},
+		elections:       map[uuid.UUID]electionRecord{},
+		electionSeq:     map[uuid.UUID]uint64{},
+		translations:    map[uuid.UUID]map[string]string{},
+		accounts:        map[uuid.UUID]accountRecord{},
+		grants:          map[uuid.UUID][]uuid.UUID{},
+		tokens:          map[uuid.UUID]tokenRecord{},
+		trash:           map[uuid.UUID]memTrashed{},
+		published:       map[draftRecord]time.Time{},
+		updates:         map[draftRecord]int64{},
+		modified:        time.Now(),
+	}}
+}
+
+func (ms *memStore) tx(ro bool, f func(tx storeTx) error) error {
+	if ro {
+		ms.RLock()
+		defer ms.RUnlock()
+
+		return f(memTx{ms.data, true})
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+
+	data := ms.data.clone()
+
+	if errTx := f(memTx{data, false}); errTx != nil {
+		return errTx
+	}
+
+	ms.data = data
+	return nil
+}
+
+type memData struct {
+	states          map[uuid.UUID]stateRecord
+	offices         map[uuid.UUID]officeRecord
+	jurisdictions   map[uuid.UUID]jurisdiction
+	stations        map[uuid.UUID]stationRecord
+	districts       map[uuid.UUID]districtRecord
+	candidates      map[uuid.UUID]candidateRecord
+	recommendations map[uuid.UUID]recommendationRecord
+	elections       map[uuid.UUID]electionRecord
+	translations    map[uuid.UUID]map[string]string
+	accounts        map[uuid.UUID]accountRecord
+	grants          map[uuid.UUID][]uuid.UUID
+	tokens          map[uuid.UUID]tokenRecord
+	audit           []auditRecord
+	changes         []changeRecord
+	trash           map[uuid.UUID]memTrashed
+	// published tells when records were published, the others are drafts.
+	published map[draftRecord]time.Time
+	// updates counts how often records have been updated, their version is one more.
+	updates  map[draftRecord]int64
+	revision int64
+	modified time.Time
+	// bumped tells whether the transaction working on this clone has bumped the revision, it's not cloned.
+	bumped bool
+
+	// electionSeq orders elections by creation like PostgreSQL's serial IDs do.
+	electionSeq  map[uuid.UUID]uint64
+	nextElection uint64
+}
+
+// clone copies everything but the records themselves, they're replaced, not modified.
+func (md *memData) clone() *memData {
+	res := &memData{
+		states:          make(map[uuid.UUID]stateRecord, len(md.states)),
+		offices:         make(map[uuid.UUID]officeRecord, len(md.offices)),
+		jurisdictions:   make(map[uuid.UUID]jurisdiction, len(md.jurisdictions)),
+		stations:        make(map[uuid.UUID]stationRecord, len(md.stations)),
+		districts:       make(map[uuid.UUID]districtRecord, len(md.districts)),
+		candidates:      make(map[uuid.UUID]candidateRecord, len(md.candidates)),
+		recommendations: make(map[uuid.UUID]recommendationRecord, len(md.recommendations)),
+		elections:       make(map[uuid.UUID]electionRecord, len(md.elections)),
+		translations:    make(map[uuid.UUID]map[string]string, len(md.translations)),
+		accounts:        make(map[uuid.UUID]accountRecord, len(md.accounts)),
+		grants:          make(map[uuid.UUID][]uuid.UUID, len(md.grants)),
+		tokens:          make(map[uuid.UUID]tokenRecord, len(md.tokens)),
+		trash:           make(map[uuid.UUID]memTrashed, len(md.trash)),
+		published:       make(map[draftRecord]time.Time, len(md.published)),
+		updates:         make(map[draftRecord]int64, len(md.updates)),
+		electionSeq:     make(map[uuid.UUID]uint64, len(md.electionSeq)),
+		nextElection:    md.nextElection,
+		revision:        md.revision,
+		modified:        md.modified,
+	}
+
+	for k, v := range md.states {
+		res.states[k] = v
+	}
+
+	for k, v := range md.offices {
+		res.offices[k] = v
+	}
+
+	for k, v := range md.jurisdictions {
+		res.jurisdictions[k] = v
+	}
+
+	for k, v := range md.stations {
+		res.stations[k] = v
+	}
+
+	for k, v := range md.districts {
+		res.districts[k] = v
+	}
+
+	for k, v := range md.candidates {
+		res.candidates[k] = v
+	}
+
+	for k, v := range md.recommendations {
+		res.recommendations[k] = v
+	}
+
+	for k, v := range md.elections {
+		res.elections[k] = v
+	}
+
+	for k, v := range md.translations {
+		res.translations[k] = v
+	}
+
+	for k, v := range md.electionSeq {
+		res.electionSeq[k] = v
+	}
+
+	for k, v := range md.accounts {
+		res.accounts[k] = v
+	}
+
+	for k, v := range md.grants {
+		res.grants[k] = v
+	}
+
+	for k, v := range md.tokens {
+		res.tokens[k] = v
+	}
+
+	for k, v := range md.trash {
+		res.trash[k] = v
+	}
+
+	for k, v := range md.published {
+		res.published[k] = v
+	}
+
+	for k, v := range md.updates {
+		res.updates[k] = v
+	}
+
+	// Appending to a full slice copies it, so the events are shared until then.
+	res.audit = md.audit[:len(md.audit):len(md.audit)]
+	res.changes = md.changes[:len(md.changes):len(md.changes)]
+
+	return res
+}
+
+type memTx struct {
+	data *memData
+	ro   bool
+}
+
+var _ storeTx = memTx{}
+
+var errReadOnly = errors.New("cannot write in a read-only transaction")
+
+// write fails unless writing is allowed.
+func (mt memTx) write() error {
+	if mt.ro {
+		return errReadOnly
+	}
+
+	return nil
+}
+
+func (mt memTx) states() ([]stateRecord, error) {
+	res := make([]stateRecord, 0, len(mt.data.states))
+	for _, s := range mt.data.states {
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+func (mt memTx) state(id uuid.UUID) (stateRecord, bool, error) {
+	s, ok := mt.data.states[id]
+	return s, ok, nil
+}
+
+func (mt memTx) stateByCode(code string) (stateRecord, bool, error) {
+	for _, s := range mt.data.states {
+		if s.IsoCode != nil && *s.IsoCode == code {
+			return s, true, nil
+		}
+	}
+
+	return stateRecord{}, false, nil
+}
+
+func (mt memTx) putState(s stateRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if s.IsoCode != nil {
+		if _, taken, _ := mt.stateByCode(*s.IsoCode); taken {
+			return errors.New("duplicate state ISO code")
+		}
+	}
+
+	mt.data.states[s.Id] = s
+	return nil
+}
+
+func (mt memTx) updateState(s stateRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.states[s.Id]
+	if !ok {
+		return false, nil
+	}
+
+	if s.IsoCode == nil {
+		s.IsoCode = old.IsoCode
+	} else if other, taken, _ := mt.stateByCode(*s.IsoCode); taken && other.Id != s.Id {
+		return false, errors.New("duplicate state ISO code")
+	}
+
+	mt.data.states[s.Id] = s
+	mt.data.updates[draftRecord{"state", s.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteState(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.states[id]; !ok {
+		return false, nil
+	}
+
+	for _, o := range mt.data.offices {
+		if o.State == id {
+			return false, referenceError{"state", "office"}
+		}
+	}
+
+	mt.discard("state", id, mt.data.states[id])
+	delete(mt.data.states, id)
+	return true, nil
+}
+
+func (mt memTx) offices(state uuid.UUID, subdivision, city string) ([]officeRecord, error) {
+	var res []officeRecord
+
+	for _, o := range mt.data.offices {
+		if o.State != state {
+			continue
+		}
+
+		if subdivision != "" || city != "" {
+			j := mt.data.jurisdictions[o.Id]
+			if !containsString(j.Subdivisions, subdivision) && !containsFold(j.Cities, city) {
+				continue
+			}
+		}
+
+		res = append(res, o)
+	}
+
+	return res, nil
+}
+
+func (mt memTx) locatedOffices() ([]officeRecord, error) {
+	var res []officeRecord
+
+	for _, o := range mt.data.offices {
+		if o.Lat != nil {
+			res = append(res, o)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) office(id uuid.UUID) (officeRecord, bool, error) {
+	o, ok := mt.data.offices[id]
+	return o, ok, nil
+}
+
+func (mt memTx) putOffice(o officeRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.states[o.State]; !ok {
+		return missingReference("state")
+	}
+
+	mt.data.offices[o.Id] = o
+	return nil
+}
+
+func (mt memTx) updateOffice(o officeRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.offices[o.Id]
+	if !ok {
+		return false, nil
+	}
+
+	o.State = old.State
+
+	mt.data.offices[o.Id] = o
+	mt.data.updates[draftRecord{"office", o.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteOffice(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.offices[id]; !ok {
+		return false, nil
+	}
+
+	for _, s := range mt.data.stations {
+		if s.Office == id {
+			return false, referenceError{"office", "station"}
+		}
+	}
+
+	mt.discard("office", id, mt.data.offices[id])
+	delete(mt.data.offices, id)
+	return true, nil
+}
+
+func (mt memTx) jurisdiction(office uuid.UUID) (jurisdiction, error) {
+	j := mt.data.jurisdictions[office]
+	return jurisdiction{append([]string{}, j.Subdivisions...), append([]string{}, j.Cities...)}, nil
+}
+
+func (mt memTx) replaceJurisdiction(office uuid.UUID, j jurisdiction) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.offices[office]; ok {
+		mt.data.jurisdictions[office] = jurisdiction{uniqueSorted(j.Subdivisions), uniqueSorted(j.Cities)}
+	}
+
+	return nil
+}
+
+func (mt memTx) stations(office, election uuid.UUID) ([]stationRecord, error) {
+	var res []stationRecord
+
+	for _, s := range mt.data.stations {
+		if s.Office == office && mt.data.districts[s.District].Election == election {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) officeStations(office uuid.UUID) ([]stationRecord, error) {
+	var res []stationRecord
+
+	for _, s := range mt.data.stations {
+		if s.Office == office {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) districtStations(district uuid.UUID) ([]stationRecord, error) {
+	var res []stationRecord
+
+	for _, s := range mt.data.stations {
+		if s.District == district {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) station(id uuid.UUID) (stationRecord, bool, error) {
+	s, ok := mt.data.stations[id]
+	return s, ok, nil
+}
+
+func (mt memTx) putStation(s stationRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.offices[s.Office]; !ok {
+		return missingReference("office")
+	}
+
+	if _, ok := mt.data.districts[s.District]; !ok {
+		return missingReference("district")
+	}
+
+	mt.data.stations[s.Id] = s
+	return nil
+}
+
+func (mt memTx) updateStation(s stationRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.stations[s.Id]
+	if !ok {
+		return false, nil
+	}
+
+	if _, ok := mt.data.districts[s.District]; !ok {
+		return false, missingReference("district")
+	}
+
+	s.Office = old.Office
+
+	mt.data.stations[s.Id] = s
+	mt.data.updates[draftRecord{"station", s.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteStation(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.stations[id]; !ok {
+		return false, nil
+	}
+
+	mt.discard("station", id, mt.data.stations[id])
+	delete(mt.data.stations, id)
+	return true, nil
+}
+
+func (mt memTx) districts(election uuid.UUID) ([]districtRecord, error) {
+	var res []districtRecord
+
+	for _, d := range mt.data.districts {
+		if d.Election == election {
+			res = append(res, d)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) district(id uuid.UUID) (districtRecord, bool, error) {
+	d, ok := mt.data.districts[id]
+	return d, ok, nil
+}
+
+func (mt memTx) putDistrict(d districtRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.elections[d.Election]; !ok {
+		return missingReference("election")
+	}
+
+	mt.data.districts[d.Id] = d
+	return nil
+}
+
+func (mt memTx) updateDistrict(d districtRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.districts[d.Id]
+	if !ok {
+		return false, nil
+	}
+
+	d.Election = old.Election
+
+	mt.data.districts[d.Id] = d
+	mt.data.updates[draftRecord{"district", d.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteDistrict(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.districts[id]; !ok {
+		return false, nil
+	}
+
+	for _, s := range mt.data.stations {
+		if s.District == id {
+			return false, referenceError{"district", "station"}
+		}
+	}
+
+	for _, c := range mt.data.candidates {
+		if c.District == id {
+			return false, referenceError{"district", "candidate"}
+		}
+	}
+
+	if _, ok := mt.data.recommendations[id]; ok {
+		return false, referenceError{"district", "recommendation"}
+	}
+
+	mt.discard("district", id, mt.data.districts[id])
+	delete(mt.data.districts, id)
+	return true, nil
+}
+
+func (mt memTx) candidates(district uuid.UUID) ([]candidateRecord, error) {
+	var res []candidateRecord
+
+	for _, c := range mt.data.candidates {
+		if c.District == district {
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) candidate(id uuid.UUID) (candidateRecord, bool, error) {
+	c, ok := mt.data.candidates[id]
+	return c, ok, nil
+}
+
+func (mt memTx) putCandidate(c candidateRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.districts[c.District]; !ok {
+		return missingReference("district")
+	}
+
+	mt.data.candidates[c.Id] = c
+	return nil
+}
+
+func (mt memTx) updateCandidate(c candidateRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.candidates[c.Id]
+	if !ok {
+		return false, nil
+	}
+
+	c.District = old.District
+
+	mt.data.candidates[c.Id] = c
+	mt.data.updates[draftRecord{"candidate", c.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteCandidate(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.candidates[id]; !ok {
+		return false, nil
+	}
+
+	for _, r := range mt.data.recommendations {
+		if r.Candidate == id {
+			return false, referenceError{"candidate", "recommendation"}
+		}
+	}
+
+	mt.discard("candidate", id, mt.data.candidates[id])
+	delete(mt.data.candidates, id)
+	return true, nil
+}
+
+func (mt memTx) recommendation(district uuid.UUID) (recommendationRecord, bool, error) {
+	r, ok := mt.data.recommendations[district]
+	return r, ok, nil
+}
+
+func (mt memTx) putRecommendation(r recommendationRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.districts[r.District]; !ok {
+		return missingReference("district")
+	}
+
+	if _, ok := mt.data.candidates[r.Candidate]; !ok {
+		return missingReference("candidate")
+	}
+
+	if _, ok := mt.data.recommendations[r.District]; ok {
+		mt.data.updates[draftRecord{"recommendation", r.District}]++
+	}
+
+	mt.data.recommendations[r.District] = r
+	return nil
+}
+
+func (mt memTx) deleteRecommendation(district uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.recommendations[district]; !ok {
+		return false, nil
+	}
+
+	delete(mt.data.recommendations, district)
+	delete(mt.data.published, draftRecord{"recommendation", district})
+	delete(mt.data.updates, draftRecord{"recommendation", district})
+	return true, nil
+}
+
+func (mt memTx) elections() ([]electionRecord, error) {
+	res := make([]electionRecord, 0, len(mt.data.elections))
+	for _, e := range mt.data.elections {
+		res = append(res, e)
+	}
+
+	return res, nil
+}
+
+func (mt memTx) election(id uuid.UUID) (electionRecord, bool, error) {
+	e, ok := mt.data.elections[id]
+	return e, ok, nil
+}
+
+func (mt memTx) latestElection() (electionRecord, bool, error) {
+	var res electionRecord
+	var found bool
+
+	for _, e := range mt.data.elections {
+		if !found || e.VotingFrom.After(res.VotingFrom) ||
+			e.VotingFrom.Equal(res.VotingFrom) && mt.data.electionSeq[e.Id] > mt.data.electionSeq[res.Id] {
+			res = e
+			found = true
+		}
+	}
+
+	return res, found, nil
+}
+
+func (mt memTx) putElection(e electionRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	mt.data.nextElection++
+	mt.data.electionSeq[e.Id] = mt.data.nextElection
+	mt.data.elections[e.Id] = e
+
+	return nil
+}
+
+func (mt memTx) updateElection(e electionRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.elections[e.Id]; !ok {
+		return false, nil
+	}
+
+	mt.data.elections[e.Id] = e
+	mt.data.updates[draftRecord{"election", e.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteElection(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.elections[id]; !ok {
+		return false, nil
+	}
+
+	for _, d := range mt.data.districts {
+		if d.Election == id {
+			return false, referenceError{"election", "district"}
+		}
+	}
+
+	mt.discard("election", id, mt.data.elections[id])
+	delete(mt.data.elections, id)
+	return true, nil
+}
+
+func (mt memTx) translations(entities []uuid.UUID, langs []string) ([]translationRecord, error) {
+	var res []translationRecord
+
+	for _, entity := range entities {
+		names := mt.data.translations[entity]
+
+		for _, lang := range langs {
+			if name, ok := names[lang]; ok {
+				res = append(res, translationRecord{entity, lang, name})
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) replaceTranslations(entity uuid.UUID, names map[string]string) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if len(names) < 1 {
+		delete(mt.data.translations, entity)
+		return nil
+	}
+
+	copied := make(map[string]string, len(names))
+	for lang, name := range names {
+		copied[lang] = name
+	}
+
+	mt.data.translations[entity] = copied
+	return nil
+}
+
+func (mt memTx) accounts() ([]accountRecord, error) {
+	res := make([]accountRecord, 0, len(mt.data.accounts))
+	for _, a := range mt.data.accounts {
+		res = append(res, a)
+	}
+
+	return res, nil
+}
+
+func (mt memTx) account(id uuid.UUID) (accountRecord, bool, error) {
+	a, ok := mt.data.accounts[id]
+	return a, ok, nil
+}
+
+func (mt memTx) accountByName(name string) (accountRecord, bool, error) {
+	for _, a := range mt.data.accounts {
+		if a.Name == name {
+			return a, true, nil
+		}
+	}
+
+	return accountRecord{}, false, nil
+}
+
+func (mt memTx) putAccount(a accountRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, taken, _ := mt.accountByName(a.Name); taken {
+		return errors.New("duplicate account name")
+	}
+
+	mt.data.accounts[a.Id] = a
+	return nil
+}
+
+func (mt memTx) updateAccount(a accountRecord) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	old, ok := mt.data.accounts[a.Id]
+	if !ok {
+		return false, nil
+	}
+
+	if other, taken, _ := mt.accountByName(a.Name); taken && other.Id != a.Id {
+		return false, errors.New("duplicate account name")
+	}
+
+	if a.Hash == nil {
+		a.Hash = old.Hash
+	}
+
+	mt.data.accounts[a.Id] = a
+	mt.data.updates[draftRecord{"account", a.Id}]++
+	return true, nil
+}
+
+func (mt memTx) deleteAccount(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.accounts[id]; !ok {
+		return false, nil
+	}
+
+	delete(mt.data.accounts, id)
+	delete(mt.data.grants, id)
+	delete(mt.data.updates, draftRecord{"account", id})
+
+	for tid, t := range mt.data.tokens {
+		if t.Account == id {
+			delete(mt.data.tokens, tid)
+		}
+	}
+
+	return true, nil
+}
+
+func (mt memTx) grants(account uuid.UUID) ([]uuid.UUID, error) {
+	res := []uuid.UUID{}
+	for _, state := range mt.data.grants[account] {
+		if _, ok := mt.data.states[state]; ok {
+			res = append(res, state)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) replaceGrants(account uuid.UUID, states []uuid.UUID) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.accounts[account]; !ok {
+		return nil
+	}
+
+	var granted []uuid.UUID
+	seen := map[uuid.UUID]struct{}{}
+
+	for _, state := range states {
+		if _, ok := mt.data.states[state]; !ok {
+			return missingReference("state")
+		}
+
+		if _, ok := seen[state]; !ok {
+			seen[state] = struct{}{}
+			granted = append(granted, state)
+		}
+	}
+
+	if len(granted) < 1 {
+		delete(mt.data.grants, account)
+	} else {
+		mt.data.grants[account] = granted
+	}
+
+	return nil
+}
+
+func (mt memTx) tokens() ([]tokenRecord, error) {
+	res := make([]tokenRecord, 0, len(mt.data.tokens))
+	for _, t := range mt.data.tokens {
+		res = append(res, t)
+	}
+
+	return res, nil
+}
+
+func (mt memTx) token(id uuid.UUID) (tokenRecord, bool, error) {
+	t, ok := mt.data.tokens[id]
+	return t, ok, nil
+}
+
+func (mt memTx) tokenByHash(hash []byte) (tokenRecord, bool, error) {
+	for _, t := range mt.data.tokens {
+		if bytes.Equal(t.Hash, hash) {
+			return t, true, nil
+		}
+	}
+
+	return tokenRecord{}, false, nil
+}
+
+func (mt memTx) putToken(t tokenRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if _, ok := mt.data.accounts[t.Account]; !ok && t.Account != uuid.Nil {
+		return missingReference("account")
+	}
+
+	if _, taken, _ := mt.tokenByHash(t.Hash); taken {
+		return errors.New("duplicate token hash")
+	}
+
+	mt.data.tokens[t.Id] = t
+	return nil
+}
+
+func (mt memTx) touchToken(id uuid.UUID, used time.Time) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if t, ok := mt.data.tokens[id]; ok {
+		t.LastUsed = &used
+		mt.data.tokens[id] = t
+	}
+
+	return nil
+}
+
+func (mt memTx) deleteToken(id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	if _, ok := mt.data.tokens[id]; !ok {
+		return false, nil
+	}
+
+	delete(mt.data.tokens, id)
+	return true, nil
+}
+
+func (mt memTx) names(entity uuid.UUID) (map[string]string, error) {
+	res := make(map[string]string, len(mt.data.translations[entity]))
+	for lang, name := range mt.data.translations[entity] {
+		res[lang] = name
+	}
+
+	return res, nil
+}
+
+func (mt memTx) version(kind string, id uuid.UUID) (int64, bool, error) {
+	var found bool
+	switch kind {
+	case "state":
+		_, found = mt.data.states[id]
+	case "office":
+		_, found = mt.data.offices[id]
+	case "station":
+		_, found = mt.data.stations[id]
+	case "district":
+		_, found = mt.data.districts[id]
+	case "candidate":
+		_, found = mt.data.candidates[id]
+	case "election":
+		_, found = mt.data.elections[id]
+	case "recommendation":
+		_, found = mt.data.recommendations[id]
+	case "account":
+		_, found = mt.data.accounts[id]
+	}
+
+	if !found {
+		return 0, false, nil
+	}
+
+	return mt.data.updates[draftRecord{kind, id}] + 1, true, nil
+}
+
+func (mt memTx) revision(at time.Time) (revisionRecord, error) {
+	res := revisionRecord{mt.data.revision, mt.data.modified}
+
+	for _, published := range mt.data.published {
+		if published.After(res.Modified) && !published.After(at) {
+			res.Modified = published
+		}
+	}
+
+	for _, r := range mt.data.recommendations {
+		if r.Published.After(res.Modified) && !r.Published.After(at) {
+			res.Modified = r.Published
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) bumpRevision() error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	if !mt.data.bumped {
+		mt.data.bumped = true
+		mt.data.revision++
+		mt.data.modified = time.Now()
+	}
+
+	return nil
+}
+
+func (mt memTx) putChange(kind string, id uuid.UUID) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	mt.data.changes = append(mt.data.changes, changeRecord{mt.data.revision, kind, id})
+	return nil
+}
+
+func (mt memTx) changes(since int64) ([]changeRecord, error) {
+	latest := map[draftRecord]int64{}
+
+	for _, c := range mt.data.changes {
+		if c.Revision > since {
+			latest[draftRecord{c.Kind, c.Id}] = c.Revision
+		}
+	}
+
+	res := make([]changeRecord, 0, len(latest))
+	for record, revision := range latest {
+		res = append(res, changeRecord{revision, record.Kind, record.Id})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Revision != res[j].Revision {
+			return res[i].Revision < res[j].Revision
+		}
+
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+
+		return res[i].Id.String() < res[j].Id.String()
+	})
+
+	return res, nil
+}
+
+func (mt memTx) putAuditEvent(e auditRecord) error {
+	if errWr := mt.write(); errWr != nil {
+		return errWr
+	}
+
+	mt.data.audit = append(mt.data.audit, e)
+	return nil
+}
+
+func (mt memTx) auditEvents(entity uuid.UUID, since *time.Time) ([]auditRecord, error) {
+	var res []auditRecord
+
+	for _, e := range mt.data.audit {
+		if (entity == uuid.Nil || e.Entity == entity) && (since == nil || !e.Time.Before(*since)) {
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+// memTrashed is a deleted record, it's moved out of its map until restored or purged.
+type memTrashed struct {
+	trashedRecord
+
+	record interface{}
+}
+
+// discard moves the record id of kind to the trash, its map entry has still to be deleted.
+func (mt memTx) discard(kind string, id uuid.UUID, record interface{}) {
+	var ruName string
+
+	switch r := record.(type) {
+	case stateRecord:
+		ruName = r.RuName
+	case officeRecord:
+		ruName = r.RuName
+	case stationRecord:
+		ruName = r.RuName
+	case districtRecord:
+		ruName = r.RuName
+	case candidateRecord:
+		ruName = r.RuName
+	case electionRecord:
+		ruName = r.RuName
+	}
+
+	mt.data.trash[id] = memTrashed{trashedRecord{kind, id, ruName, time.Now()}, record}
+}
+
+func (mt memTx) trash() ([]trashedRecord, error) {
+	res := make([]trashedRecord, 0, len(mt.data.trash))
+	for _, t := range mt.data.trash {
+		res = append(res, t.trashedRecord)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].DeletedAt.Equal(res[j].DeletedAt) {
+			return res[i].DeletedAt.After(res[j].DeletedAt)
+		}
+
+		return res[i].Id.String() < res[j].Id.String()
+	})
+
+	return res, nil
+}
+
+func (mt memTx) restore(kind string, id uuid.UUID) (bool, error) {
+	if errWr := mt.write(); errWr != nil {
+		return false, errWr
+	}
+
+	t, ok := mt.data.trash[id]
+	if !ok || t.Kind != kind {
+		return false, nil
+	}
+
+	switch r := t.record.(type) {
+	case stateRecord:
+		if r.IsoCode != nil {
+			if _, taken, _ := mt.stateByCode(*r.IsoCode); taken {
+				return false, errIsoCodeTaken
+			}
+		}
+
+		mt.data.states[id] = r
+	case officeRecord:
+		if _, live := mt.data.states[r.State]; !live {
+			return false, errParentDeleted
+		}
+
+		mt.data.offices[id] = r
+	case stationRecord:
+		_, liveOffice := mt.data.offices[r.Office]
+		_, liveDistrict := mt.data.districts[r.District]
+
+		if !liveOffice || !liveDistrict {
+			return false, errParentDeleted
+		}
+
+		mt.data.stations[id] = r
+	case districtRecord:
+		if _, live := mt.data.elections[r.Election]; !live {
+			return false, errParentDeleted
+		}
+
+		mt.data.districts[id] = r
+	case candidateRecord:
+		if _, live := mt.data.districts[r.District]; !live {
+			return false, errParentDeleted
+		}
+
+		mt.data.candidates[id] = r
+	case electionRecord:
+		mt.data.elections[id] = r
+	}
+
+	delete(mt.data.trash, id)
+	return true, nil
+}
+
+func (mt memTx) purge(before time.Time) (int, error) {
+	if errWr := mt.write(); errWr != nil {
+		return 0, errWr
+	}
+
+	var purged int
+
+	for id, t := range mt.data.trash {
+		if !t.DeletedAt.Before(before) {
+			continue
+		}
+
+		switch t.Kind {
+		case "state":
+			for account, states := range mt.data.grants {
+				var kept []uuid.UUID
+				for _, state := range states {
+					if state != id {
+						kept = append(kept, state)
+					}
+				}
+
+				mt.data.grants[account] = kept
+			}
+		case "office":
+			delete(mt.data.jurisdictions, id)
+		case "election":
+			delete(mt.data.electionSeq, id)
+		}
+
+		delete(mt.data.translations, id)
+		delete(mt.data.published, draftRecord{t.Kind, id})
+		delete(mt.data.updates, draftRecord{t.Kind, id})
+		delete(mt.data.trash, id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// records lists all live records which may be drafts.
+func (mt memTx) records() []draftRecord {
+	var res []draftRecord
+
+	for id := range mt.data.states {
+		res = append(res, draftRecord{"state", id})
+	}
+
+	for id := range mt.data.offices {
+		res = append(res, draftRecord{"office", id})
+	}
+
+	for id := range mt.data.stations {
+		res = append(res, draftRecord{"station", id})
+	}
+
+	for id := range mt.data.districts {
+		res = append(res, draftRecord{"district", id})
+	}
+
+	for id := range mt.data.candidates {
+		res = append(res, draftRecord{"candidate", id})
+	}
+
+	for id := range mt.data.elections {
+		res = append(res, draftRecord{"election", id})
+	}
+
+	for district := range mt.data.recommendations {
+		res = append(res, draftRecord{"recommendation", district})
+	}
+
+	return res
+}
+
+func (mt memTx) drafts(at time.Time) ([]draftRecord, error) {
+	var res []draftRecord
+
+	for _, record := range mt.records() {
+		if published, ok := mt.data.published[record]; !ok || published.After(at) {
+			res = append(res, record)
+		}
+	}
+
+	return res, nil
+}
+
+func (mt memTx) publish(at time.Time) ([]draftRecord, error) {
+	if errWr := mt.write(); errWr != nil {
+		return nil, errWr
+	}
+
+	var published []draftRecord
+
+	for _, record := range mt.records() {
+		if _, ok := mt.data.published[record]; !ok {
+			mt.data.published[record] = at
+			published = append(published, record)
+		}
+	}
+
+	return published, nil
+}
+
+func missingReference(table string) error {
+	return errors.New("no such " + table + " to reference")
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if strings.EqualFold(s, needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func uniqueSorted(s []string) []string {
+	res := make([]string, 0, len(s))
+	seen := make(map[string]struct{}, len(s))
+
+	for _, v := range s {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			res = append(res, v)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
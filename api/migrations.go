@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+// migrationLock is the advisory lock key ("voteapi") serializing migrations across replicas.
+const migrationLock = 0x766f7465617069
+
+type migration struct {
+	up, down string
+}
+
+// migrations are applied in order, the version of each one is its index plus one. Never edit or
+// reorder already released ones, append new ones instead. The first ones had been bootstrapped by
+// CREATE TABLE IF NOT EXISTS before and have to tolerate existing tables.
+var migrations = []migration{
+	{
+		up: `CREATE TABLE IF NOT EXISTS state (
+	int_id  SMALLSERIAL PRIMARY KEY,
+	ext_id  UUID NOT NULL UNIQUE,
+	ru_name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS office (
+	int_id  SERIAL PRIMARY KEY,
+	ext_id  UUID NOT NULL UNIQUE,
+	state   SMALLINT NOT NULL REFERENCES state(int_id),
+	ru_name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS district (
+	int_id  SMALLSERIAL PRIMARY KEY,
+	ext_id  UUID NOT NULL UNIQUE,
+	ru_name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS station (
+	int_id   BIGSERIAL PRIMARY KEY,
+	ext_id   UUID NOT NULL UNIQUE,
+	office   INT NOT NULL REFERENCES office(int_id),
+	district SMALLINT NOT NULL REFERENCES district(int_id),
+	ru_name  VARCHAR(255) NOT NULL
+)`,
+		down: `DROP TABLE station;
+DROP TABLE district;
+DROP TABLE office;
+DROP TABLE state`,
+	},
+	{
+		up: `CREATE TABLE IF NOT EXISTS candidate (
+	int_id   SERIAL PRIMARY KEY,
+	ext_id   UUID NOT NULL UNIQUE,
+	district SMALLINT NOT NULL REFERENCES district(int_id),
+	ru_name  VARCHAR(255) NOT NULL,
+	party    VARCHAR(255) NOT NULL,
+	status   VARCHAR(15) NOT NULL
+)`,
+		down: `DROP TABLE candidate`,
+	},
+	{
+		up: `CREATE TABLE IF NOT EXISTS recommendation (
+	district  SMALLINT PRIMARY KEY REFERENCES district(int_id),
+	candidate INT NOT NULL REFERENCES candidate(int_id),
+	note      TEXT NOT NULL,
+	published TIMESTAMP WITH TIME ZONE NOT NULL
+)`,
+		down: `DROP TABLE recommendation`,
+	},
+	{
+		up: `CREATE TABLE IF NOT EXISTS election (
+	int_id      SMALLSERIAL PRIMARY KEY,
+	ext_id      UUID NOT NULL UNIQUE,
+	ru_name     VARCHAR(255) NOT NULL,
+	voting_from DATE NOT NULL,
+	voting_to   DATE NOT NULL,
+	status      VARCHAR(15) NOT NULL
+);
+
+ALTER TABLE district ADD COLUMN IF NOT EXISTS election SMALLINT REFERENCES election(int_id);
+
+INSERT INTO election(ext_id, ru_name, voting_from, voting_to, status)
+SELECT md5(random()::TEXT || clock_timestamp()::TEXT)::UUID,
+	'Выборы депутатов Государственной Думы', '2021-09-17', '2021-09-19', 'active'
+WHERE EXISTS (SELECT 1 FROM district WHERE election IS NULL) AND NOT EXISTS (SELECT 1 FROM election);
+
+UPDATE district SET election=(SELECT MIN(int_id) FROM election) WHERE election IS NULL;
+
+ALTER TABLE district ALTER COLUMN election SET NOT NULL`,
+		down: `ALTER TABLE district DROP COLUMN election;
+DROP TABLE election`,
+	},
+	{
+		up: `CREATE TABLE translation (
+	entity UUID NOT NULL,
+	lang   VARCHAR(35) NOT NULL,
+	name   VARCHAR(255) NOT NULL,
+	PRIMARY KEY (entity, lang)
+)`,
+		down: `DROP TABLE translation`,
+	},
+	{
+		up: `ALTER TABLE office ADD COLUMN lat DOUBLE PRECISION, ADD COLUMN lon DOUBLE PRECISION,
+	ADD COLUMN address VARCHAR(1023);
+
+ALTER TABLE station ADD COLUMN lat DOUBLE PRECISION, ADD COLUMN lon DOUBLE PRECISION,
+	ADD COLUMN address VARCHAR(1023)`,
+		down: `ALTER TABLE station DROP COLUMN address, DROP COLUMN lon, DROP COLUMN lat;
+ALTER TABLE office DROP COLUMN address, DROP COLUMN lon, DROP COLUMN lat`,
+	},
+	{
+		up: `CREATE TABLE jurisdiction (
+	office INT NOT NULL REFERENCES office(int_id) ON DELETE CASCADE,
+	kind   VARCHAR(15) NOT NULL,
+	area   VARCHAR(255) NOT NULL,
+	PRIMARY KEY (office, kind, area)
+);
+
+CREATE INDEX jurisdiction_area ON jurisdiction(area)`,
+		down: `DROP TABLE jurisdiction`,
+	},
+	{
+		up:   `ALTER TABLE state ADD COLUMN iso_code CHAR(2) UNIQUE`,
+		down: `ALTER TABLE state DROP COLUMN iso_code`,
+	},
+	{
+		up: `CREATE TABLE account (
+	int_id SMALLSERIAL PRIMARY KEY,
+	ext_id UUID NOT NULL UNIQUE,
+	name   VARCHAR(255) NOT NULL UNIQUE,
+	hash   BYTEA NOT NULL,
+	role   VARCHAR(15) NOT NULL
+)`,
+		down: `DROP TABLE account`,
+	},
+	{
+		up: `CREATE TABLE account_state (
+	account SMALLINT NOT NULL REFERENCES account(int_id) ON DELETE CASCADE,
+	state   SMALLINT NOT NULL REFERENCES state(int_id) ON DELETE CASCADE,
+	PRIMARY KEY (account, state)
+)`,
+		down: `DROP TABLE account_state`,
+	},
+	{
+		up: `CREATE TABLE token (
+	int_id    SERIAL PRIMARY KEY,
+	ext_id    UUID NOT NULL UNIQUE,
+	account   SMALLINT REFERENCES account(int_id) ON DELETE CASCADE,
+	name      VARCHAR(255) NOT NULL,
+	hash      BYTEA NOT NULL UNIQUE,
+	scopes    VARCHAR(1023) NOT NULL,
+	created   TIMESTAMP WITH TIME ZONE NOT NULL,
+	expires   TIMESTAMP WITH TIME ZONE,
+	last_used TIMESTAMP WITH TIME ZONE
+)`,
+		down: `DROP TABLE token`,
+	},
+	{
+		// Actors and entities are no foreign keys, so the events survive them.
+		up: `CREATE TABLE audit_event (
+	int_id      BIGSERIAL PRIMARY KEY,
+	ext_id      UUID NOT NULL UNIQUE,
+	time        TIMESTAMP WITH TIME ZONE NOT NULL,
+	actor       UUID NOT NULL,
+	actor_name  VARCHAR(255) NOT NULL,
+	action      VARCHAR(15) NOT NULL,
+	entity_type VARCHAR(31) NOT NULL,
+	entity      UUID NOT NULL,
+	before      JSONB,
+	after       JSONB
+);
+CREATE INDEX audit_event_entity ON audit_event (entity);
+CREATE INDEX audit_event_time ON audit_event (time)`,
+		down: `DROP TABLE audit_event`,
+	},
+	{
+		up: `ALTER TABLE state ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE office ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE station ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE district ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE candidate ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE election ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE state DROP CONSTRAINT state_iso_code_key;
+CREATE UNIQUE INDEX state_iso_code ON state (iso_code) WHERE deleted_at IS NULL`,
+		down: `DELETE FROM station WHERE deleted_at IS NOT NULL;
+DELETE FROM candidate WHERE deleted_at IS NOT NULL;
+DELETE FROM office WHERE deleted_at IS NOT NULL;
+DELETE FROM district WHERE deleted_at IS NOT NULL;
+DELETE FROM state WHERE deleted_at IS NOT NULL;
+DELETE FROM election WHERE deleted_at IS NOT NULL;
+DROP INDEX state_iso_code;
+ALTER TABLE state ADD CONSTRAINT state_iso_code_key UNIQUE (iso_code);
+ALTER TABLE election DROP COLUMN deleted_at;
+ALTER TABLE candidate DROP COLUMN deleted_at;
+ALTER TABLE district DROP COLUMN deleted_at;
+ALTER TABLE station DROP COLUMN deleted_at;
+ALTER TABLE office DROP COLUMN deleted_at;
+ALTER TABLE state DROP COLUMN deleted_at`,
+	},
+	{
+		// Everything so far has been public, only new records are drafts.
+		// Not to be confused with recommendation.published, the date shown to users.
+		up: `ALTER TABLE state ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE office ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE station ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE district ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE candidate ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE election ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE recommendation ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+UPDATE state SET published_at=NOW();
+UPDATE office SET published_at=NOW();
+UPDATE station SET published_at=NOW();
+UPDATE district SET published_at=NOW();
+UPDATE candidate SET published_at=NOW();
+UPDATE election SET published_at=NOW();
+UPDATE recommendation SET published_at=NOW()`,
+		down: `ALTER TABLE recommendation DROP COLUMN published_at;
+ALTER TABLE election DROP COLUMN published_at;
+ALTER TABLE candidate DROP COLUMN published_at;
+ALTER TABLE district DROP COLUMN published_at;
+ALTER TABLE station DROP COLUMN published_at;
+ALTER TABLE office DROP COLUMN published_at;
+ALTER TABLE state DROP COLUMN published_at`,
+	},
+	{
+		// A single row bumped by every transaction changing public data.
+		up: `CREATE TABLE data_revision (
+	revision BIGINT NOT NULL,
+	modified_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+INSERT INTO data_revision(revision, modified_at) VALUES (0, NOW())`,
+		down: `DROP TABLE data_revision`,
+	},
+	{
+		up: `ALTER TABLE state ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE office ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE station ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE district ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE candidate ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE election ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE recommendation ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE account ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+		down: `ALTER TABLE account DROP COLUMN version;
+ALTER TABLE recommendation DROP COLUMN version;
+ALTER TABLE election DROP COLUMN version;
+ALTER TABLE candidate DROP COLUMN version;
+ALTER TABLE district DROP COLUMN version;
+ALTER TABLE station DROP COLUMN version;
+ALTER TABLE office DROP COLUMN version;
+ALTER TABLE state DROP COLUMN version`,
+	},
+	{
+		// Everything there is counts as changed once for clients syncing from scratch.
+		up: `CREATE TABLE change_log (
+	revision BIGINT NOT NULL,
+	kind TEXT NOT NULL,
+	entity UUID NOT NULL,
+	PRIMARY KEY (revision, kind, entity)
+);
+UPDATE data_revision SET revision=revision+1, modified_at=NOW();
+INSERT INTO change_log(revision, kind, entity)
+	SELECT r.revision, 'state', s.ext_id FROM state s, data_revision r WHERE s.deleted_at IS NULL;
+INSERT INTO change_log(revision, kind, entity)
+	SELECT r.revision, 'office', o.ext_id FROM office o, data_revision r WHERE o.deleted_at IS NULL;
+INSERT INTO change_log(revision, kind, entity)
+	SELECT r.revision, 'station', s.ext_id FROM station s, data_revision r WHERE s.deleted_at IS NULL;
+INSERT INTO change_log(revision, kind, entity)
+	SELECT r.revision, 'district', d.ext_id FROM district d, data_revision r WHERE d.deleted_at IS NULL`,
+		down: `DROP TABLE change_log`,
+	},
+}
+
+func migrateCmd(args []string) {
+	var cmd string
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		if errMU := withMigrationLock(migrateUp); errMU != nil {
+			log.WithFields(log.Fields{"error": errMU.Error()}).Fatal("Couldn't migrate database schema")
+		}
+	case "down":
+		if errMD := withMigrationLock(migrateDown); errMD != nil {
+			log.WithFields(log.Fields{"error": errMD.Error()}).Fatal("Couldn't migrate database schema")
+		}
+	case "status":
+		if errMS := withMigrationLock(migrationStatus); errMS != nil {
+			log.WithFields(log.Fields{"error": errMS.Error()}).Fatal("Couldn't query database schema")
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: api migrate up|down|status")
+		os.Exit(2)
+	}
+}
+
+func withMigrationLock(f func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, errCn := db.Conn(ctx)
+	if errCn != nil {
+		return errCn
+	}
+
+	defer conn.Close()
+
+	if _, errEx := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); errEx != nil {
+		return errEx
+	}
+
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	{
+		_, errEx := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INT PRIMARY KEY,
+	applied TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`)
+		if errEx != nil {
+			return errEx
+		}
+	}
+
+	return f(conn)
+}
+
+func appliedMigrations(conn *sql.Conn) (map[int]time.Time, error) {
+	type row struct {
+		Version int
+		Applied time.Time
+	}
+
+	tx, errBg := conn.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if errBg != nil {
+		return nil, errBg
+	}
+
+	rawRows, errFA := fetchAll(tx, row{}, "SELECT version, applied FROM schema_migrations")
+	_ = tx.Rollback()
+
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	rows := rawRows.([]row)
+	res := make(map[int]time.Time, len(rows))
+
+	for _, row := range rows {
+		res[row.Version] = row.Applied
+	}
+
+	return res, nil
+}
+
+func migrateUp(conn *sql.Conn) error {
+	applied, errAM := appliedMigrations(conn)
+	if errAM != nil {
+		return errAM
+	}
+
+	for version := len(migrations) + 1; ; version++ {
+		if _, ok := applied[version]; !ok {
+			break
+		}
+
+		log.WithFields(log.Fields{"version": version}).Warn("Database schema is newer than this program")
+	}
+
+	for i, m := range migrations {
+		version := i + 1
+		if _, ok := applied[version]; ok {
+			continue
+		}
+
+		log.WithFields(log.Fields{"version": version}).Info("Applying database schema migration")
+
+		errMg := migrateTx(conn, m.up, `INSERT INTO schema_migrations(version) VALUES ($1)`, version)
+		if errMg != nil {
+			return fmt.Errorf("migration %d: %s", version, errMg.Error())
+		}
+	}
+
+	return nil
+}
+
+func migrateDown(conn *sql.Conn) error {
+	applied, errAM := appliedMigrations(conn)
+	if errAM != nil {
+		return errAM
+	}
+
+	for version := len(migrations); version > 0; version-- {
+		if _, ok := applied[version]; ok {
+			log.WithFields(log.Fields{"version": version}).Info("Reverting database schema migration")
+
+			errMg := migrateTx(
+				conn, migrations[version-1].down, `DELETE FROM schema_migrations WHERE version=$1`, version,
+			)
+			if errMg != nil {
+				return fmt.Errorf("migration %d: %s", version, errMg.Error())
+			}
+
+			return nil
+		}
+	}
+
+	log.Info("No database schema migrations to revert")
+	return nil
+}
+
+func migrateTx(conn *sql.Conn, ddl, bookkeeping string, version int) error {
+	tx, errBg := conn.BeginTx(context.Background(), nil)
+	if errBg != nil {
+		return errBg
+	}
+
+	if _, errEx := tx.Exec(ddl); errEx != nil {
+		_ = tx.Rollback()
+		return errEx
+	}
+
+	if _, errEx := tx.Exec(bookkeeping, version); errEx != nil {
+		_ = tx.Rollback()
+		return errEx
+	}
+
+	return tx.Commit()
+}
+
+func migrationStatus(conn *sql.Conn) error {
+	applied, errAM := appliedMigrations(conn)
+	if errAM != nil {
+		return errAM
+	}
+
+	for i := range migrations {
+		if at, ok := applied[i+1]; ok {
+			fmt.Printf("%4d  applied %s\n", i+1, at.Format(time.RFC3339))
+		} else {
+			fmt.Printf("%4d  pending\n", i+1)
+		}
+	}
+
+	return nil
+}
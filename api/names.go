@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"golang.org/x/text/language"
+	"strings"
+)
+
+func validateNames(names map[string]string) (map[string]string, *apiError) {
+	if names == nil {
+		return nil, nil
+	}
+
+	res := make(map[string]string, len(names))
+
+	for lang, name := range names {
+		tag, errPs := language.Parse(lang)
+		if errPs != nil {
+			return nil, &apiError{code: "validation.language_invalid", field: "names." + lang}
+		}
+
+		if base, _ := tag.Base(); base.String() == "ru" {
+			return nil, &apiError{code: "validation.name_redundant", field: "names." + lang}
+		}
+
+		if strings.TrimSpace(name) == "" {
+			return nil, &apiError{code: "validation.name_missing", field: "names." + lang}
+		}
+
+		res[tag.String()] = name
+	}
+
+	return res, nil
+}
+
+// putNames replaces all translations of entity unless names is nil.
+func putNames(tx storeTx, entity uuid.UUID, names map[string]string) error {
+	if names == nil {
+		return nil
+	}
+
+	return tx.replaceTranslations(entity, names)
+}
+
+// langPrefs lists the languages the client prefers over Russian, most preferred first.
+func langPrefs(ctx iris.Context) []string {
+	ctx.Header("Vary", "Accept-Language")
+
+	raw := ctx.URLParam("lang")
+	if raw == "" {
+		raw = ctx.GetHeader("Accept-Language")
+	}
+
+	tags, _, errPA := language.ParseAcceptLanguage(raw)
+	if errPA != nil {
+		return nil
+	}
+
+	var res []string
+	seen := map[string]struct{}{}
+
+	for _, tag := range tags {
+		candidates := []string{tag.String()}
+		if base, conf := tag.Base(); conf != language.No {
+			if base.String() == "ru" {
+				break
+			}
+
+			candidates = append(candidates, base.String())
+		}
+
+		for _, lang := range candidates {
+			if _, ok := seen[lang]; !ok {
+				seen[lang] = struct{}{}
+				res = append(res, lang)
+			}
+		}
+	}
+
+	return res
+}
+
+// localize replaces the Russian names by the most preferred translations available.
+func localize(tx storeTx, prefs []string, names map[uuid.UUID]string) error {
+	if len(prefs) < 1 || len(names) < 1 {
+		return nil
+	}
+
+	entities := make([]uuid.UUID, 0, len(names))
+	for entity := range names {
+		entities = append(entities, entity)
+	}
+
+	rows, errTr := tx.translations(entities, prefs)
+	if errTr != nil {
+		return errTr
+	}
+
+	rank := make(map[string]int, len(prefs))
+	for i, lang := range prefs {
+		rank[lang] = i
+	}
+
+	best := map[uuid.UUID]int{}
+
+	for _, row := range rows {
+		if r, ok := best[row.Entity]; !ok || rank[row.Lang] < r {
+			best[row.Entity] = rank[row.Lang]
+			names[row.Entity] = row.Name
+		}
+	}
+
+	return nil
+}
@@ -1,241 +1,481 @@
 package main
 
 import (
-	"database/sql"
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
+	"sort"
 	"strings"
 )
 
 func putOffices(ctx iris.Context) {
 	var payload struct {
-		RuName string `json:"ru_name"`
-	}
-
-	type row struct {
-		IntId int16
+		RuName       string            `json:"ru_name"`
+		Names        map[string]string `json:"names"`
+		Jurisdiction *jurisdiction     `json:"jurisdiction"`
+		location
 	}
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.Jurisdiction.validate(); invalid != nil {
+		respondError(ctx, *invalid)
 		return
 	}
 
 	uid, errNR := uuid.NewRandom()
 	if errNR != nil {
-		ctx.StatusCode(500)
-		ctx.JSON(errorResponse{errNR.Error()})
+		respondInternal(ctx, errNR)
 		return
 	}
 
-	var found bool
+	p := principalOf(ctx)
+	var found, allowed bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			rawRows, errFA := fetchAll(tx, row{}, `SELECT int_id FROM state WHERE ext_id=$1`, extId)
-			if errFA != nil {
-				return errFA
+		errTx := doTx(false, func(tx storeTx) error {
+			_, ok, errSt := tx.state(extId)
+			if errSt != nil {
+				return errSt
 			}
 
-			rows := rawRows.([]row)
-			if found = len(rows) > 0; !found {
+			if found = ok; !found {
 				return nil
 			}
 
-			_, errEx := tx.Exec(
-				`INSERT INTO office(ext_id, state, ru_name) VALUES ($1, $2, $3)`, uid, rows[0].IntId, payload.RuName,
-			)
-			return errEx
+			var errME error
+			if allowed, errME = mayEdit(tx, p, extId); errME != nil || !allowed {
+				return errME
+			}
+
+			return audited(tx, p, "office", uid, func() error {
+				errPO := tx.putOffice(officeRecord{
+					uid, extId, payload.RuName, payload.Lat, payload.Lon, payload.Address,
+				})
+				if errPO != nil {
+					return errPO
+				}
+
+				if errPN := putNames(tx, uid, names); errPN != nil {
+					return errPN
+				}
+
+				return putJurisdiction(tx, uid, payload.Jurisdiction)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if found {
+	if !found {
+		respondError(ctx, apiError{code: "not_found.state"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else {
 		ctx.StatusCode(201)
 		ctx.JSON(struct {
 			Id uuid.UUID `json:"id"`
 		}{uid})
-	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such state"})
 	}
 }
 
 func getOffices(ctx iris.Context) {
-	type state struct {
-		IntId int16
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
 	}
 
-	type office struct {
-		ExtId  uuid.UUID
-		RuName string
-	}
+	subdivision := strings.ToUpper(strings.TrimSpace(ctx.URLParam("subdivision")))
+	city := strings.TrimSpace(ctx.URLParam("city"))
 
-	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
-	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+	drafts, ok := draftsParam(ctx)
+	if !ok {
 		return
 	}
 
+	prefs := langPrefs(ctx)
 	var found bool
-	var offices []office
+	var res map[uuid.UUID]string
 
 	{
-		errTx := doTx(true, func(tx *sql.Tx) error {
-			rawStates, errFA1 := fetchAll(tx, state{}, `SELECT int_id FROM state WHERE ext_id=$1`, extId)
-			if errFA1 != nil {
-				return errFA1
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			_, ok, errSt := tx.state(extId)
+			if errSt != nil {
+				return errSt
 			}
 
-			states := rawStates.([]state)
-			if found = len(states) > 0; !found {
+			if found = ok; !found {
 				return nil
 			}
 
-			rawOffices, errFA2 := fetchAll(
-				tx, office{}, "SELECT ext_id, ru_name FROM office WHERE state=$1", states[0].IntId,
-			)
-			if errFA2 != nil {
-				return errFA2
+			offices, errOs := tx.offices(extId, subdivision, city)
+			if errOs != nil {
+				return errOs
 			}
 
-			offices = rawOffices.([]office)
-			return nil
+			res = make(map[uuid.UUID]string, len(offices))
+
+			for _, row := range offices {
+				res[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, res)
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
 	if found {
-		res := make(map[uuid.UUID]string, len(offices))
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.state"})
+	}
+}
+
+func getOffice(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
 
-		for _, row := range offices {
-			res[row.ExtId] = row.RuName
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var office officeRecord
+	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errOf error
+			if office, found, errOf = tx.office(extId); errOf != nil || !found {
+				return errOf
+			}
+
+			if version, _, errOf = tx.version("office", extId); errOf != nil {
+				return errOf
+			}
+
+			name = map[uuid.UUID]string{office.Id: office.RuName}
+			return localize(tx, prefs, name)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
 		}
+	}
 
-		ctx.JSON(res)
+	if found {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			Id     uuid.UUID `json:"id"`
+			State  uuid.UUID `json:"state"`
+			RuName string    `json:"ru_name"`
+			Name   string    `json:"name"`
+			location
+		}{office.Id, office.State, office.RuName, name[office.Id], location{office.Lat, office.Lon, office.Address}})
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such state"})
+		respondError(ctx, apiError{code: "not_found.office"})
 	}
 }
 
 func postOffices(ctx iris.Context) {
 	var payload struct {
-		RuName string `json:"ru_name"`
+		RuName       string            `json:"ru_name"`
+		Names        map[string]string `json:"names"`
+		Jurisdiction *jurisdiction     `json:"jurisdiction"`
+		location
 	}
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
-	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+	body, errGB := ctx.GetBody()
+	if errGB != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errGB.Error()})
+		return
+	}
+
+	if errUm := json.Unmarshal(body, &payload); errUm != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errUm.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
 		return
 	}
 
-	var found bool
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	given := newGivenLocation(body)
+	if invalid := given.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.Jurisdiction.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var found, allowed, current bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`UPDATE office SET ru_name=$1 WHERE ext_id=$2`, payload.RuName, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errME error
+			if found, allowed, errME = mayEditOffice(tx, p, extId); errME != nil || !allowed {
+				return errME
+			}
+
+			if current, errME = checkVersion(tx, "office", extId, versions); errME != nil || !current {
+				return errME
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			old, _, errOl := tx.office(extId)
+			if errOl != nil {
+				return errOl
 			}
 
-			found = rows > 0
+			payload.keep(location{old.Lat, old.Lon, old.Address}, given)
+
+			return audited(tx, p, "office", extId, func() error {
+				_, errUO := tx.updateOffice(officeRecord{
+					Id: extId, RuName: payload.RuName, Lat: payload.Lat, Lon: payload.Lon, Address: payload.Address,
+				})
+				if errUO != nil {
+					return errUO
+				}
 
-			return nil
+				if errPN := putNames(tx, extId, names); errPN != nil {
+					return errPN
+				}
+
+				return putJurisdiction(tx, extId, payload.Jurisdiction)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if found {
-		ctx.StatusCode(204)
+	if !found {
+		respondError(ctx, apiError{code: "not_found.office"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such office"})
+		ctx.StatusCode(204)
 	}
 }
 
 func deleteOffices(ctx iris.Context) {
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
-	var found bool
+	cascade, errCP := cascadeParam(ctx)
+	if errCP != nil {
+		respondError(ctx, apiError{code: "validation.cascade_invalid", field: "cascade"})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var found, allowed, current bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`DELETE FROM office WHERE ext_id=$1`, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errME error
+			if found, allowed, errME = mayEditOffice(tx, p, extId); errME != nil || !allowed {
+				return errME
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			if current, errME = checkVersion(tx, "office", extId, versions); errME != nil || !current {
+				return errME
 			}
 
-			found = rows > 0
+			if cascade {
+				if errDp := deleteDependents(tx, p, extId, officeDependents); errDp != nil {
+					return errDp
+				}
+			}
 
-			return nil
+			return audited(tx, p, "office", extId, func() error {
+				_, errDO := tx.deleteOffice(extId)
+				return errDO
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			if referenced(errTx) {
+				respondReferenced(ctx, "conflict.office_referenced", extId, officeDependents)
+			} else {
+				respondInternal(ctx, errTx)
+			}
+
 			return
 		}
 	}
 
-	if found {
-		ctx.StatusCode(204)
+	if !found {
+		respondError(ctx, apiError{code: "not_found.office"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such office"})
+		ctx.StatusCode(204)
+	}
+}
+
+func getNearestOffices(ctx iris.Context) {
+	type nearOffice struct {
+		officeRecord
+		distance float64
+	}
+
+	lat, errLat := ctx.URLParamFloat64("lat")
+	if errLat != nil || !(lat >= -90 && lat <= 90) {
+		respondError(ctx, apiError{code: "validation.lat_invalid", field: "lat"})
+		return
+	}
+
+	lon, errLon := ctx.URLParamFloat64("lon")
+	if errLon != nil || !(lon >= -180 && lon <= 180) {
+		respondError(ctx, apiError{code: "validation.lon_invalid", field: "lon"})
+		return
+	}
+
+	limit := ctx.URLParamIntDefault("limit", 5)
+	if limit < 1 || limit > 100 {
+		respondError(ctx, apiError{code: "validation.limit_out_of_range", field: "limit"})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var nearest []nearOffice
+	var localized map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			offices, errLO := tx.locatedOffices()
+			if errLO != nil {
+				return errLO
+			}
+
+			nearest = make([]nearOffice, 0, len(offices))
+
+			for _, row := range offices {
+				nearest = append(nearest, nearOffice{row, greatCircle(lat, lon, *row.Lat, *row.Lon)})
+			}
+
+			sort.Slice(nearest, func(i, j int) bool {
+				return nearest[i].distance < nearest[j].distance
+			})
+
+			if len(nearest) > limit {
+				nearest = nearest[:limit]
+			}
+
+			localized = make(map[uuid.UUID]string, len(nearest))
+
+			for _, row := range nearest {
+				localized[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, localized)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
 	}
+
+	type resOffice struct {
+		Id     uuid.UUID `json:"id"`
+		State  uuid.UUID `json:"state"`
+		RuName string    `json:"ru_name"`
+		Name   string    `json:"name"`
+		location
+		Distance float64 `json:"distance_km"`
+	}
+
+	res := make([]resOffice, 0, len(nearest))
+
+	for i := range nearest {
+		row := &nearest[i]
+
+		res = append(res, resOffice{
+			row.Id, row.State, row.RuName, localized[row.Id],
+			location{row.Lat, row.Lon, row.Address}, row.distance,
+		})
+	}
+
+	ctx.JSON(res)
 }
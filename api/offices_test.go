@@ -0,0 +1,175 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestOfficesRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	offices := "/v1/states/" + state + "/offices"
+
+	anon.GET(offices).Expect().Status(200).JSON().Object().Empty()
+
+	id := create(admin, offices, map[string]interface{}{
+		"ru_name": "Генеральное консульство в Мюнхене", "lat": 48.1371, "lon": 11.5754,
+		"names": map[string]string{"de": "Generalkonsulat in München"},
+	})
+
+	anon.GET(offices).Expect().Status(200).JSON().Object().
+		Equal(map[string]string{id: "Генеральное консульство в Мюнхене"})
+
+	anon.GET(offices).WithHeader("Accept-Language", "de-DE").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{id: "Generalkonsulat in München"})
+
+	admin.POST("/v1/offices/" + id).WithJSON(map[string]string{"ru_name": "Консульство в Мюнхене"}).
+		Expect().Status(204)
+
+	anon.GET(offices).Expect().Status(200).JSON().Object().Equal(map[string]string{id: "Консульство в Мюнхене"})
+	anon.GET("/v1/offices/"+id).Expect().Status(200).JSON().Object().
+		ValueEqual("lat", 48.1371).ValueEqual("lon", 11.5754)
+
+	// Coordinates are replaced together, null clears them.
+	admin.POST("/v1/offices/"+id).WithJSON(map[string]interface{}{"ru_name": "Консульство в Мюнхене", "lat": nil}).
+		Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.location_incomplete").ValueEqual("field", "lon")
+	admin.POST("/v1/offices/" + id).WithJSON(map[string]interface{}{
+		"ru_name": "Консульство в Мюнхене", "lat": nil, "lon": nil,
+	}).Expect().Status(204)
+
+	anon.GET("/v1/offices/"+id).Expect().Status(200).JSON().Object().ValueEqual("lat", nil).ValueEqual("lon", nil)
+
+	admin.DELETE("/v1/offices/" + id).Expect().Status(204)
+	admin.DELETE("/v1/offices/" + id).Expect().Status(404)
+
+	anon.GET(offices).Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestOfficesNotFound(t *testing.T) {
+	anon, admin := newTestApi(t)
+	missing := uuid.New().String()
+
+	anon.GET("/v1/states/" + missing + "/offices").Expect().Status(404)
+
+	admin.PUT("/v1/states/"+missing+"/offices").WithJSON(map[string]string{"ru_name": "Посольство"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.state")
+
+	admin.POST("/v1/offices/"+missing).WithJSON(map[string]string{"ru_name": "Посольство"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.office")
+
+	anon.GET("/v1/offices/" + missing + "/jurisdiction").Expect().Status(404)
+}
+
+func TestOfficesValidation(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	offices := "/v1/states/" + create(admin, "/v1/states", map[string]string{"ru_name": "Германия"}) + "/offices"
+
+	admin.PUT(offices).WithJSON(map[string]interface{}{"ru_name": "Посольство", "lat": 52.5}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.location_incomplete")
+
+	admin.PUT(offices).WithJSON(map[string]interface{}{"ru_name": "Посольство", "lat": 91, "lon": 0}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.lat_invalid")
+
+	admin.PUT(offices).WithJSON(map[string]interface{}{
+		"ru_name": "Посольство", "jurisdiction": map[string]interface{}{"subdivisions": []string{"Bayern"}},
+	}).Expect().Status(400).JSON().Object().ValueEqual("code", "validation.subdivision_invalid")
+}
+
+func TestOfficesJurisdiction(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	offices := "/v1/states/" + state + "/offices"
+
+	munich := create(admin, offices, map[string]interface{}{
+		"ru_name": "Генеральное консульство в Мюнхене",
+		"jurisdiction": map[string]interface{}{
+			"subdivisions": []string{"de-by", "DE-BW"}, "cities": []string{"Nürnberg"},
+		},
+	})
+
+	berlin := create(admin, offices, map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "jurisdiction": map[string]interface{}{"subdivisions": []string{"DE-BE"}},
+	})
+
+	anon.GET("/v1/offices/" + munich + "/jurisdiction").Expect().Status(200).JSON().Object().
+		Equal(map[string][]string{"subdivisions": {"DE-BW", "DE-BY"}, "cities": {"Nürnberg"}})
+
+	anon.GET(offices).WithQuery("subdivision", "de-by").Expect().Status(200).JSON().Object().
+		Keys().ContainsOnly(munich)
+
+	anon.GET(offices).WithQuery("city", "NÜRNBERG").Expect().Status(200).JSON().Object().
+		Keys().ContainsOnly(munich)
+
+	anon.GET(offices).WithQuery("subdivision", "DE-BE").Expect().Status(200).JSON().Object().
+		Keys().ContainsOnly(berlin)
+
+	anon.GET(offices).WithQuery("subdivision", "DE-HH").Expect().Status(200).JSON().Object().Empty()
+
+	// Leaving out the jurisdiction keeps it, an empty one removes it.
+	admin.POST("/v1/offices/" + berlin).WithJSON(map[string]string{"ru_name": "Посольство в Берлине"}).
+		Expect().Status(204)
+
+	anon.GET("/v1/offices/" + berlin + "/jurisdiction").Expect().Status(200).JSON().Object().
+		Value("subdivisions").Array().Elements("DE-BE")
+
+	admin.POST("/v1/offices/" + berlin).WithJSON(map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "jurisdiction": map[string]interface{}{},
+	}).Expect().Status(204)
+
+	anon.GET("/v1/offices/" + berlin + "/jurisdiction").Expect().Status(200).JSON().Object().
+		Equal(map[string][]string{"subdivisions": {}, "cities": {}})
+}
+
+func TestOfficesNearest(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	offices := "/v1/states/" + state + "/offices"
+
+	berlin := create(admin, offices, map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "lat": 52.5163, "lon": 13.3777, "address": "Unter den Linden 63-65",
+	})
+
+	munich := create(admin, offices, map[string]interface{}{
+		"ru_name": "Генеральное консульство в Мюнхене", "lat": 48.1371, "lon": 11.5754,
+	})
+
+	create(admin, offices, map[string]string{"ru_name": "Консульство без координат"})
+
+	nearest := anon.GET("/v1/offices/nearest").WithQuery("lat", 48.7758).WithQuery("lon", 9.1829).
+		Expect().Status(200).JSON().Array()
+
+	nearest.Length().Equal(2)
+	nearest.Element(0).Object().ValueEqual("id", munich).ValueEqual("state", state)
+	nearest.Element(1).Object().ValueEqual("id", berlin).ValueEqual("address", "Unter den Linden 63-65")
+	nearest.Element(1).Object().Value("distance_km").Number().InRange(500, 530)
+
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 48.7758).WithQuery("lon", 9.1829).WithQuery("limit", 1).
+		Expect().Status(200).JSON().Array().Length().Equal(1)
+
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 100).WithQuery("lon", 0).Expect().Status(400)
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 0).Expect().Status(400)
+
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 0).WithQuery("lon", 0).WithQuery("limit", 0).
+		Expect().Status(400)
+}
+
+func TestOfficesSingle(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "lat": 52.5163, "lon": 13.3777,
+	})
+
+	anon.GET("/v1/offices/"+office).Expect().Status(200).JSON().Object().
+		ValueEqual("id", office).ValueEqual("state", state).ValueEqual("name", "Посольство в Берлине").
+		ValueEqual("lat", 52.5163).ValueEqual("address", nil)
+
+	anon.GET("/v1/offices/"+state).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.office")
+	anon.GET("/v1/offices/nearest").WithQuery("lat", 52.5).WithQuery("lon", 13.4).Expect().Status(200)
+}
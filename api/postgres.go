@@ -0,0 +1,928 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+type pgTx struct {
+	tx *sql.Tx
+	// bumped tells whether the transaction has bumped the revision.
+	bumped *bool
+}
+
+var _ storeTx = pgTx{}
+
+// exec runs a DML statement and tells whether it affected any rows.
+func (pt pgTx) exec(query string, args ...interface{}) (bool, error) {
+	res, errEx := pt.tx.Exec(query, args...)
+	if errEx != nil {
+		return false, errEx
+	}
+
+	rows, errRA := res.RowsAffected()
+	if errRA != nil {
+		return false, errRA
+	}
+
+	return rows > 0, nil
+}
+
+const stateColumns = "ext_id, ru_name, iso_code"
+
+func (pt pgTx) states() ([]stateRecord, error) {
+	rows, errFA := fetchAll(pt.tx, stateRecord{}, "SELECT "+stateColumns+" FROM state WHERE deleted_at IS NULL")
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]stateRecord), nil
+}
+
+func (pt pgTx) state(id uuid.UUID) (stateRecord, bool, error) {
+	rows, errFA := fetchAll(
+		pt.tx, stateRecord{}, "SELECT "+stateColumns+" FROM state WHERE deleted_at IS NULL AND ext_id=$1", id,
+	)
+	if errFA != nil || len(rows.([]stateRecord)) < 1 {
+		return stateRecord{}, false, errFA
+	}
+
+	return rows.([]stateRecord)[0], true, nil
+}
+
+func (pt pgTx) stateByCode(code string) (stateRecord, bool, error) {
+	rows, errFA := fetchAll(
+		pt.tx, stateRecord{}, "SELECT "+stateColumns+" FROM state WHERE deleted_at IS NULL AND iso_code=$1", code,
+	)
+	if errFA != nil || len(rows.([]stateRecord)) < 1 {
+		return stateRecord{}, false, errFA
+	}
+
+	return rows.([]stateRecord)[0], true, nil
+}
+
+func (pt pgTx) putState(s stateRecord) error {
+	_, errEx := pt.tx.Exec(`INSERT INTO state(ext_id, ru_name, iso_code) VALUES ($1, $2, $3)`, s.Id, s.RuName, s.IsoCode)
+	return errEx
+}
+
+func (pt pgTx) updateState(s stateRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE state SET version=version+1, ru_name=$1, iso_code=COALESCE($2, iso_code) `+
+			`WHERE ext_id=$3 AND deleted_at IS NULL`,
+		s.RuName, s.IsoCode, s.Id,
+	)
+}
+
+func (pt pgTx) deleteState(id uuid.UUID) (bool, error) {
+	return pt.softDelete("state", id, "office")
+}
+
+const officeColumns = "o.ext_id, s.ext_id, o.ru_name, o.lat, o.lon, o.address " +
+	"FROM office o INNER JOIN state s ON s.int_id=o.state AND o.deleted_at IS NULL"
+
+func (pt pgTx) offices(state uuid.UUID, subdivision, city string) ([]officeRecord, error) {
+	var rows interface{}
+	var errFA error
+
+	if subdivision == "" && city == "" {
+		rows, errFA = fetchAll(pt.tx, officeRecord{}, "SELECT "+officeColumns+" WHERE s.ext_id=$1", state)
+	} else {
+		rows, errFA = fetchAll(
+			pt.tx, officeRecord{},
+			"SELECT "+officeColumns+" WHERE s.ext_id=$1 AND o.int_id IN (SELECT office FROM jurisdiction "+
+				"WHERE kind='subdivision' AND area=$2 OR kind='city' AND LOWER(area)=LOWER($3))",
+			state, subdivision, city,
+		)
+	}
+
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]officeRecord), nil
+}
+
+func (pt pgTx) locatedOffices() ([]officeRecord, error) {
+	rows, errFA := fetchAll(pt.tx, officeRecord{}, "SELECT "+officeColumns+" WHERE o.lat IS NOT NULL")
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]officeRecord), nil
+}
+
+func (pt pgTx) office(id uuid.UUID) (officeRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, officeRecord{}, "SELECT "+officeColumns+" WHERE o.ext_id=$1", id)
+	if errFA != nil || len(rows.([]officeRecord)) < 1 {
+		return officeRecord{}, false, errFA
+	}
+
+	return rows.([]officeRecord)[0], true, nil
+}
+
+func (pt pgTx) putOffice(o officeRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO office(ext_id, state, ru_name, lat, lon, address) `+
+			`SELECT $1, int_id, $3, $4, $5, $6 FROM state WHERE ext_id=$2 AND deleted_at IS NULL`,
+		o.Id, o.State, o.RuName, o.Lat, o.Lon, o.Address,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateOffice(o officeRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE office SET version=version+1, ru_name=$1, lat=$2, lon=$3, address=$4 `+
+			`WHERE ext_id=$5 AND deleted_at IS NULL`,
+		o.RuName, o.Lat, o.Lon, o.Address, o.Id,
+	)
+}
+
+func (pt pgTx) deleteOffice(id uuid.UUID) (bool, error) {
+	return pt.softDelete("office", id, "station")
+}
+
+func (pt pgTx) jurisdiction(office uuid.UUID) (jurisdiction, error) {
+	type row struct {
+		Kind string
+		Area string
+	}
+
+	rawRows, errFA := fetchAll(
+		pt.tx, row{},
+		"SELECT j.kind, j.area FROM jurisdiction j INNER JOIN office o ON o.int_id=j.office "+
+			"WHERE o.ext_id=$1 ORDER BY j.area",
+		office,
+	)
+	if errFA != nil {
+		return jurisdiction{}, errFA
+	}
+
+	res := jurisdiction{[]string{}, []string{}}
+
+	for _, row := range rawRows.([]row) {
+		switch row.Kind {
+		case "subdivision":
+			res.Subdivisions = append(res.Subdivisions, row.Area)
+		case "city":
+			res.Cities = append(res.Cities, row.Area)
+		}
+	}
+
+	return res, nil
+}
+
+func (pt pgTx) replaceJurisdiction(office uuid.UUID, j jurisdiction) error {
+	{
+		_, errEx := pt.tx.Exec(
+			`DELETE FROM jurisdiction WHERE office=(SELECT int_id FROM office WHERE ext_id=$1)`, office,
+		)
+		if errEx != nil {
+			return errEx
+		}
+	}
+
+	for kind, areas := range map[string][]string{"subdivision": j.Subdivisions, "city": j.Cities} {
+		for _, area := range areas {
+			_, errEx := pt.tx.Exec(
+				`INSERT INTO jurisdiction(office, kind, area) SELECT int_id, $2, $3 FROM office WHERE ext_id=$1 `+
+					`ON CONFLICT DO NOTHING`,
+				office, kind, area,
+			)
+			if errEx != nil {
+				return errEx
+			}
+		}
+	}
+
+	return nil
+}
+
+const stationColumns = "s.ext_id, o.ext_id, d.ext_id, s.ru_name, s.lat, s.lon, s.address " +
+	"FROM station s INNER JOIN office o ON o.int_id=s.office AND s.deleted_at IS NULL " +
+	"INNER JOIN district d ON d.int_id=s.district"
+
+func (pt pgTx) stations(office, election uuid.UUID) ([]stationRecord, error) {
+	rows, errFA := fetchAll(
+		pt.tx, stationRecord{},
+		"SELECT "+stationColumns+" WHERE o.ext_id=$1 AND d.election=(SELECT int_id FROM election WHERE ext_id=$2)",
+		office, election,
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]stationRecord), nil
+}
+
+func (pt pgTx) officeStations(office uuid.UUID) ([]stationRecord, error) {
+	rows, errFA := fetchAll(pt.tx, stationRecord{}, "SELECT "+stationColumns+" WHERE o.ext_id=$1", office)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]stationRecord), nil
+}
+
+func (pt pgTx) districtStations(district uuid.UUID) ([]stationRecord, error) {
+	rows, errFA := fetchAll(pt.tx, stationRecord{}, "SELECT "+stationColumns+" WHERE d.ext_id=$1", district)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]stationRecord), nil
+}
+
+func (pt pgTx) station(id uuid.UUID) (stationRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, stationRecord{}, "SELECT "+stationColumns+" WHERE s.ext_id=$1", id)
+	if errFA != nil || len(rows.([]stationRecord)) < 1 {
+		return stationRecord{}, false, errFA
+	}
+
+	return rows.([]stationRecord)[0], true, nil
+}
+
+func (pt pgTx) putStation(s stationRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO station(ext_id, office, district, ru_name, lat, lon, address) `+
+			`SELECT $1, o.int_id, d.int_id, $4, $5, $6, $7 FROM office o, district d `+
+			`WHERE o.ext_id=$2 AND d.ext_id=$3 AND o.deleted_at IS NULL AND d.deleted_at IS NULL`,
+		s.Id, s.Office, s.District, s.RuName, s.Lat, s.Lon, s.Address,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateStation(s stationRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE station SET version=version+1, ru_name=$1, district=(SELECT int_id FROM district WHERE ext_id=$2), `+
+			`lat=$3, lon=$4, address=$5 WHERE ext_id=$6 AND deleted_at IS NULL`,
+		s.RuName, s.District, s.Lat, s.Lon, s.Address, s.Id,
+	)
+}
+
+func (pt pgTx) deleteStation(id uuid.UUID) (bool, error) {
+	return pt.softDelete("station", id)
+}
+
+const districtColumns = "d.ext_id, e.ext_id, d.ru_name " +
+	"FROM district d INNER JOIN election e ON e.int_id=d.election AND d.deleted_at IS NULL"
+
+func (pt pgTx) districts(election uuid.UUID) ([]districtRecord, error) {
+	rows, errFA := fetchAll(pt.tx, districtRecord{}, "SELECT "+districtColumns+" WHERE e.ext_id=$1", election)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]districtRecord), nil
+}
+
+func (pt pgTx) district(id uuid.UUID) (districtRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, districtRecord{}, "SELECT "+districtColumns+" WHERE d.ext_id=$1", id)
+	if errFA != nil || len(rows.([]districtRecord)) < 1 {
+		return districtRecord{}, false, errFA
+	}
+
+	return rows.([]districtRecord)[0], true, nil
+}
+
+func (pt pgTx) putDistrict(d districtRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO district(ext_id, election, ru_name) `+
+			`SELECT $1, int_id, $3 FROM election WHERE ext_id=$2 AND deleted_at IS NULL`,
+		d.Id, d.Election, d.RuName,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateDistrict(d districtRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE district SET version=version+1, ru_name=$1 WHERE ext_id=$2 AND deleted_at IS NULL`, d.RuName, d.Id,
+	)
+}
+
+func (pt pgTx) deleteDistrict(id uuid.UUID) (bool, error) {
+	return pt.softDelete("district", id, "station", "candidate", "recommendation")
+}
+
+const candidateColumns = "c.ext_id, d.ext_id, c.ru_name, c.party, c.status " +
+	"FROM candidate c INNER JOIN district d ON d.int_id=c.district AND c.deleted_at IS NULL"
+
+func (pt pgTx) candidates(district uuid.UUID) ([]candidateRecord, error) {
+	rows, errFA := fetchAll(pt.tx, candidateRecord{}, "SELECT "+candidateColumns+" WHERE d.ext_id=$1", district)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]candidateRecord), nil
+}
+
+func (pt pgTx) candidate(id uuid.UUID) (candidateRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, candidateRecord{}, "SELECT "+candidateColumns+" WHERE c.ext_id=$1", id)
+	if errFA != nil || len(rows.([]candidateRecord)) < 1 {
+		return candidateRecord{}, false, errFA
+	}
+
+	return rows.([]candidateRecord)[0], true, nil
+}
+
+func (pt pgTx) putCandidate(c candidateRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO candidate(ext_id, district, ru_name, party, status) `+
+			`SELECT $1, int_id, $3, $4, $5 FROM district WHERE ext_id=$2 AND deleted_at IS NULL`,
+		c.Id, c.District, c.RuName, c.Party, c.Status,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateCandidate(c candidateRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE candidate SET version=version+1, ru_name=$1, party=$2, status=$3 WHERE ext_id=$4 AND deleted_at IS NULL`,
+		c.RuName, c.Party, c.Status, c.Id,
+	)
+}
+
+func (pt pgTx) deleteCandidate(id uuid.UUID) (bool, error) {
+	return pt.softDelete("candidate", id, "recommendation")
+}
+
+func (pt pgTx) recommendation(district uuid.UUID) (recommendationRecord, bool, error) {
+	rows, errFA := fetchAll(
+		pt.tx, recommendationRecord{},
+		"SELECT d.ext_id, c.ext_id, r.note, r.published FROM recommendation r "+
+			"INNER JOIN district d ON d.int_id=r.district INNER JOIN candidate c ON c.int_id=r.candidate "+
+			"WHERE d.ext_id=$1",
+		district,
+	)
+	if errFA != nil || len(rows.([]recommendationRecord)) < 1 {
+		return recommendationRecord{}, false, errFA
+	}
+
+	return rows.([]recommendationRecord)[0], true, nil
+}
+
+func (pt pgTx) putRecommendation(r recommendationRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO recommendation(district, candidate, note, published) `+
+			`SELECT d.int_id, c.int_id, $3, $4 FROM district d, candidate c WHERE d.ext_id=$1 AND c.ext_id=$2 `+
+			`ON CONFLICT (district) DO UPDATE `+
+			`SET candidate=EXCLUDED.candidate, note=EXCLUDED.note, published=EXCLUDED.published, `+
+			`version=recommendation.version+1`,
+		r.District, r.Candidate, r.Note, r.Published,
+	)
+	return errEx
+}
+
+func (pt pgTx) deleteRecommendation(district uuid.UUID) (bool, error) {
+	return pt.exec(`DELETE FROM recommendation WHERE district=(SELECT int_id FROM district WHERE ext_id=$1)`, district)
+}
+
+const electionColumns = "ext_id, ru_name, voting_from, voting_to, status FROM election WHERE deleted_at IS NULL"
+
+func (pt pgTx) elections() ([]electionRecord, error) {
+	rows, errFA := fetchAll(pt.tx, electionRecord{}, "SELECT "+electionColumns)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]electionRecord), nil
+}
+
+func (pt pgTx) election(id uuid.UUID) (electionRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, electionRecord{}, "SELECT "+electionColumns+" AND ext_id=$1", id)
+	if errFA != nil || len(rows.([]electionRecord)) < 1 {
+		return electionRecord{}, false, errFA
+	}
+
+	return rows.([]electionRecord)[0], true, nil
+}
+
+func (pt pgTx) latestElection() (electionRecord, bool, error) {
+	rows, errFA := fetchAll(
+		pt.tx, electionRecord{}, "SELECT "+electionColumns+" ORDER BY voting_from DESC, int_id DESC LIMIT 1",
+	)
+	if errFA != nil || len(rows.([]electionRecord)) < 1 {
+		return electionRecord{}, false, errFA
+	}
+
+	return rows.([]electionRecord)[0], true, nil
+}
+
+func (pt pgTx) putElection(e electionRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO election(ext_id, ru_name, voting_from, voting_to, status) VALUES ($1, $2, $3, $4, $5)`,
+		e.Id, e.RuName, e.VotingFrom, e.VotingTo, e.Status,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateElection(e electionRecord) (bool, error) {
+	return pt.exec(
+		`UPDATE election SET version=version+1, ru_name=$1, voting_from=$2, voting_to=$3, status=$4 `+
+			`WHERE ext_id=$5 AND deleted_at IS NULL`,
+		e.RuName, e.VotingFrom, e.VotingTo, e.Status, e.Id,
+	)
+}
+
+func (pt pgTx) deleteElection(id uuid.UUID) (bool, error) {
+	return pt.softDelete("election", id, "district")
+}
+
+func (pt pgTx) translations(entities []uuid.UUID, langs []string) ([]translationRecord, error) {
+	ids := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, entity.String())
+	}
+
+	rows, errFA := fetchAll(
+		pt.tx, translationRecord{},
+		"SELECT entity, lang, name FROM translation WHERE entity=ANY($1::UUID[]) AND lang=ANY($2)",
+		pq.Array(ids), pq.Array(langs),
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]translationRecord), nil
+}
+
+func (pt pgTx) replaceTranslations(entity uuid.UUID, names map[string]string) error {
+	if _, errEx := pt.tx.Exec(`DELETE FROM translation WHERE entity=$1`, entity); errEx != nil {
+		return errEx
+	}
+
+	for lang, name := range names {
+		_, errEx := pt.tx.Exec(`INSERT INTO translation(entity, lang, name) VALUES ($1, $2, $3)`, entity, lang, name)
+		if errEx != nil {
+			return errEx
+		}
+	}
+
+	return nil
+}
+
+const accountColumns = "ext_id, name, hash, role"
+
+func (pt pgTx) accounts() ([]accountRecord, error) {
+	rows, errFA := fetchAll(pt.tx, accountRecord{}, "SELECT "+accountColumns+" FROM account")
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]accountRecord), nil
+}
+
+func (pt pgTx) account(id uuid.UUID) (accountRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, accountRecord{}, "SELECT "+accountColumns+" FROM account WHERE ext_id=$1", id)
+	if errFA != nil || len(rows.([]accountRecord)) < 1 {
+		return accountRecord{}, false, errFA
+	}
+
+	return rows.([]accountRecord)[0], true, nil
+}
+
+func (pt pgTx) accountByName(name string) (accountRecord, bool, error) {
+	rows, errFA := fetchAll(pt.tx, accountRecord{}, "SELECT "+accountColumns+" FROM account WHERE name=$1", name)
+	if errFA != nil || len(rows.([]accountRecord)) < 1 {
+		return accountRecord{}, false, errFA
+	}
+
+	return rows.([]accountRecord)[0], true, nil
+}
+
+func (pt pgTx) putAccount(a accountRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO account(ext_id, name, hash, role) VALUES ($1, $2, $3, $4)`, a.Id, a.Name, a.Hash, a.Role,
+	)
+	return errEx
+}
+
+func (pt pgTx) updateAccount(a accountRecord) (bool, error) {
+	if a.Hash == nil {
+		return pt.exec(`UPDATE account SET version=version+1, name=$1, role=$2 WHERE ext_id=$3`, a.Name, a.Role, a.Id)
+	}
+
+	return pt.exec(
+		`UPDATE account SET version=version+1, name=$1, hash=$2, role=$3 WHERE ext_id=$4`, a.Name, a.Hash, a.Role, a.Id,
+	)
+}
+
+func (pt pgTx) deleteAccount(id uuid.UUID) (bool, error) {
+	return pt.exec(`DELETE FROM account WHERE ext_id=$1`, id)
+}
+
+func (pt pgTx) grants(account uuid.UUID) ([]uuid.UUID, error) {
+	rows, errFA := fetchAll(
+		pt.tx, struct{ State uuid.UUID }{},
+		`SELECT s.ext_id FROM account_state g INNER JOIN state s ON s.int_id=g.state `+
+			`INNER JOIN account a ON a.int_id=g.account WHERE a.ext_id=$1 AND s.deleted_at IS NULL`,
+		account,
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	res := make([]uuid.UUID, 0, len(rows.([]struct{ State uuid.UUID })))
+	for _, row := range rows.([]struct{ State uuid.UUID }) {
+		res = append(res, row.State)
+	}
+
+	return res, nil
+}
+
+func (pt pgTx) replaceGrants(account uuid.UUID, states []uuid.UUID) error {
+	_, errEx := pt.tx.Exec(
+		`DELETE FROM account_state WHERE account=(SELECT int_id FROM account WHERE ext_id=$1)`, account,
+	)
+	if errEx != nil || len(states) < 1 {
+		return errEx
+	}
+
+	ids := make([]string, 0, len(states))
+	for _, state := range states {
+		ids = append(ids, state.String())
+	}
+
+	_, errEx = pt.tx.Exec(
+		`INSERT INTO account_state(account, state) SELECT a.int_id, s.int_id FROM account a, state s `+
+			`WHERE a.ext_id=$1 AND s.ext_id=ANY($2::UUID[])`,
+		account, pq.Array(ids),
+	)
+	return errEx
+}
+
+// tokenRow is a tokenRecord as stored, with space-separated scopes like OAuth's.
+type tokenRow struct {
+	Id       uuid.UUID
+	Account  uuid.UUID
+	Name     string
+	Hash     []byte
+	Scopes   string
+	Created  time.Time
+	Expires  *time.Time
+	LastUsed *time.Time
+}
+
+const tokenColumns = "t.ext_id, a.ext_id, t.name, t.hash, t.scopes, t.created, t.expires, t.last_used " +
+	"FROM token t LEFT JOIN account a ON a.int_id=t.account"
+
+func (pt pgTx) fetchTokens(query string, args ...interface{}) ([]tokenRecord, error) {
+	rows, errFA := fetchAll(pt.tx, tokenRow{}, "SELECT "+tokenColumns+query, args...)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	res := make([]tokenRecord, 0, len(rows.([]tokenRow)))
+	for _, row := range rows.([]tokenRow) {
+		res = append(res, tokenRecord{
+			row.Id, row.Account, row.Name, row.Hash, strings.Fields(row.Scopes), row.Created, row.Expires, row.LastUsed,
+		})
+	}
+
+	return res, nil
+}
+
+func (pt pgTx) tokens() ([]tokenRecord, error) {
+	return pt.fetchTokens("")
+}
+
+func (pt pgTx) token(id uuid.UUID) (tokenRecord, bool, error) {
+	rows, errFT := pt.fetchTokens(" WHERE t.ext_id=$1", id)
+	if errFT != nil || len(rows) < 1 {
+		return tokenRecord{}, false, errFT
+	}
+
+	return rows[0], true, nil
+}
+
+func (pt pgTx) tokenByHash(hash []byte) (tokenRecord, bool, error) {
+	rows, errFT := pt.fetchTokens(" WHERE t.hash=$1", hash)
+	if errFT != nil || len(rows) < 1 {
+		return tokenRecord{}, false, errFT
+	}
+
+	return rows[0], true, nil
+}
+
+func (pt pgTx) putToken(t tokenRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO token(ext_id, account, name, hash, scopes, created, expires) `+
+			`VALUES ($1, (SELECT int_id FROM account WHERE ext_id=$2), $3, $4, $5, $6, $7)`,
+		t.Id, t.Account, t.Name, t.Hash, strings.Join(t.Scopes, " "), t.Created, t.Expires,
+	)
+	return errEx
+}
+
+func (pt pgTx) touchToken(id uuid.UUID, used time.Time) error {
+	_, errEx := pt.tx.Exec(`UPDATE token SET last_used=$1 WHERE ext_id=$2`, used, id)
+	return errEx
+}
+
+func (pt pgTx) deleteToken(id uuid.UUID) (bool, error) {
+	return pt.exec(`DELETE FROM token WHERE ext_id=$1`, id)
+}
+
+func (pt pgTx) names(entity uuid.UUID) (map[string]string, error) {
+	rows, errFA := fetchAll(
+		pt.tx, translationRecord{}, "SELECT entity, lang, name FROM translation WHERE entity=$1", entity,
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	res := map[string]string{}
+	for _, row := range rows.([]translationRecord) {
+		res[row.Lang] = row.Name
+	}
+
+	return res, nil
+}
+
+// nullJson is json as a query parameter, NULL if nil.
+func nullJson(json []byte) interface{} {
+	if json == nil {
+		return nil
+	}
+
+	return string(json)
+}
+
+func (pt pgTx) version(kind string, id uuid.UUID) (int64, bool, error) {
+	var query string
+	switch kind {
+	case "recommendation":
+		query = `SELECT r.version FROM recommendation r INNER JOIN district d ON d.int_id=r.district ` +
+			`WHERE d.ext_id=$1 AND d.deleted_at IS NULL`
+	case "account":
+		query = `SELECT version FROM account WHERE ext_id=$1`
+	default:
+		query = `SELECT version FROM ` + kind + ` WHERE ext_id=$1 AND deleted_at IS NULL`
+	}
+
+	var version int64
+	switch errQR := pt.tx.QueryRow(query, id).Scan(&version); errQR {
+	case nil:
+		return version, true, nil
+	case sql.ErrNoRows:
+		return 0, false, nil
+	default:
+		return 0, false, errQR
+	}
+}
+
+func (pt pgTx) revision(at time.Time) (revisionRecord, error) {
+	published := make([]string, 0, len(publishable)+1)
+	for _, table := range append(publishable, "recommendation") {
+		published = append(published, `SELECT MAX(published_at) FROM `+table+` WHERE published_at <= $1`)
+	}
+
+	published = append(published, `SELECT MAX(published) FROM recommendation WHERE published <= $1`)
+
+	rows, errFA := fetchAll(
+		pt.tx, revisionRecord{},
+		`SELECT revision, GREATEST(modified_at, (SELECT MAX(p) FROM (`+strings.Join(published, " UNION ALL ")+
+			`) AS published(p))) FROM data_revision`,
+		at,
+	)
+	if errFA != nil {
+		return revisionRecord{}, errFA
+	}
+
+	return rows.([]revisionRecord)[0], nil
+}
+
+func (pt pgTx) bumpRevision() error {
+	if *pt.bumped {
+		return nil
+	}
+
+	if _, errEx := pt.tx.Exec(`UPDATE data_revision SET revision=revision+1, modified_at=NOW()`); errEx != nil {
+		return errEx
+	}
+
+	*pt.bumped = true
+	return nil
+}
+
+func (pt pgTx) putChange(kind string, id uuid.UUID) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO change_log(revision, kind, entity) SELECT revision, $1, $2 FROM data_revision `+
+			`ON CONFLICT DO NOTHING`,
+		kind, id,
+	)
+	return errEx
+}
+
+func (pt pgTx) changes(since int64) ([]changeRecord, error) {
+	rows, errFA := fetchAll(
+		pt.tx, changeRecord{},
+		`SELECT MAX(revision), kind, entity FROM change_log WHERE revision > $1 GROUP BY kind, entity `+
+			`ORDER BY 1, 2, 3`,
+		since,
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]changeRecord), nil
+}
+
+func (pt pgTx) putAuditEvent(e auditRecord) error {
+	_, errEx := pt.tx.Exec(
+		`INSERT INTO audit_event(ext_id, time, actor, actor_name, action, entity_type, entity, before, after) `+
+			`VALUES ($1, $2, $3, $4, $5, $6, $7, $8::JSONB, $9::JSONB)`,
+		e.Id, e.Time, e.Actor, e.ActorName, e.Action, e.EntityType, e.Entity, nullJson(e.Before), nullJson(e.After),
+	)
+	return errEx
+}
+
+func (pt pgTx) auditEvents(entity uuid.UUID, since *time.Time) ([]auditRecord, error) {
+	var entityParam interface{}
+	if entity != uuid.Nil {
+		entityParam = entity
+	}
+
+	rows, errFA := fetchAll(
+		pt.tx, auditRecord{},
+		`SELECT ext_id, time, actor, actor_name, action, entity_type, entity, before, after FROM audit_event `+
+			`WHERE ($1::UUID IS NULL OR entity=$1) AND ($2::TIMESTAMPTZ IS NULL OR time >= $2) ORDER BY int_id`,
+		entityParam, since,
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]auditRecord), nil
+}
+
+// softDeleted are the tables of records which are only marked deleted at first, referencing ones first.
+var softDeleted = []string{"station", "candidate", "office", "district", "state", "election"}
+
+// softDeleteParents are the tables the soft-deleted ones reference, each by a column named after the table.
+var softDeleteParents = map[string][]string{
+	"office":    {"state"},
+	"station":   {"office", "district"},
+	"district":  {"election"},
+	"candidate": {"district"},
+}
+
+// softDelete marks the record id of table deleted unless live records of the referencing tables reference it.
+func (pt pgTx) softDelete(table string, id uuid.UUID, referencing ...string) (bool, error) {
+	for _, ref := range referencing {
+		live := ""
+		if ref != "recommendation" {
+			live = " AND r.deleted_at IS NULL"
+		}
+
+		var exists bool
+		errQR := pt.tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM `+ref+` r INNER JOIN `+table+` t ON t.int_id=r.`+table+
+				` WHERE t.ext_id=$1`+live+`)`,
+			id,
+		).Scan(&exists)
+		if errQR != nil {
+			return false, errQR
+		}
+
+		if exists {
+			return false, referenceError{table, ref}
+		}
+	}
+
+	return pt.exec(`UPDATE `+table+` SET deleted_at=NOW() WHERE ext_id=$1 AND deleted_at IS NULL`, id)
+}
+
+func (pt pgTx) trash() ([]trashedRecord, error) {
+	queries := make([]string, 0, len(softDeleted))
+	for _, table := range softDeleted {
+		queries = append(
+			queries, `SELECT '`+table+`', ext_id, ru_name, deleted_at FROM `+table+` WHERE deleted_at IS NOT NULL`,
+		)
+	}
+
+	rows, errFA := fetchAll(
+		pt.tx, trashedRecord{}, strings.Join(queries, " UNION ALL ")+" ORDER BY deleted_at DESC, ext_id",
+	)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]trashedRecord), nil
+}
+
+func (pt pgTx) restore(kind string, id uuid.UUID) (bool, error) {
+	var deleted bool
+	errQR := pt.tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM `+kind+` WHERE ext_id=$1 AND deleted_at IS NOT NULL)`, id,
+	).Scan(&deleted)
+	if errQR != nil || !deleted {
+		return false, errQR
+	}
+
+	for _, parent := range softDeleteParents[kind] {
+		var parentDeleted bool
+		errQR := pt.tx.QueryRow(
+			`SELECT p.deleted_at IS NOT NULL FROM `+kind+` t INNER JOIN `+parent+` p ON p.int_id=t.`+parent+
+				` WHERE t.ext_id=$1`,
+			id,
+		).Scan(&parentDeleted)
+		if errQR != nil {
+			return false, errQR
+		}
+
+		if parentDeleted {
+			return false, errParentDeleted
+		}
+	}
+
+	if kind == "state" {
+		var taken bool
+		errQR := pt.tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM state l INNER JOIN state t ON t.iso_code=l.iso_code `+
+				`WHERE t.ext_id=$1 AND l.deleted_at IS NULL)`,
+			id,
+		).Scan(&taken)
+		if errQR != nil {
+			return false, errQR
+		}
+
+		if taken {
+			return false, errIsoCodeTaken
+		}
+	}
+
+	return pt.exec(`UPDATE `+kind+` SET deleted_at=NULL WHERE ext_id=$1`, id)
+}
+
+func (pt pgTx) purge(before time.Time) (int, error) {
+	var purged []string
+
+	for _, table := range softDeleted {
+		rows, errFA := fetchAll(
+			pt.tx, struct{ Id string }{}, `DELETE FROM `+table+` WHERE deleted_at < $1 RETURNING ext_id`, before,
+		)
+		if errFA != nil {
+			return 0, errFA
+		}
+
+		for _, row := range rows.([]struct{ Id string }) {
+			purged = append(purged, row.Id)
+		}
+	}
+
+	if len(purged) > 0 {
+		_, errEx := pt.tx.Exec(`DELETE FROM translation WHERE entity=ANY($1::UUID[])`, pq.Array(purged))
+		if errEx != nil {
+			return 0, errEx
+		}
+	}
+
+	return len(purged), nil
+}
+
+// publishable are the tables of records which are drafts until published, see publish.
+var publishable = []string{"state", "office", "station", "district", "candidate", "election"}
+
+func (pt pgTx) drafts(at time.Time) ([]draftRecord, error) {
+	queries := make([]string, 0, len(publishable)+1)
+	for _, table := range publishable {
+		queries = append(queries, `SELECT '`+table+`', ext_id FROM `+table+
+			` WHERE deleted_at IS NULL AND (published_at IS NULL OR published_at > $1)`)
+	}
+
+	queries = append(queries, `SELECT 'recommendation', d.ext_id FROM recommendation r `+
+		`INNER JOIN district d ON d.int_id=r.district WHERE r.published_at IS NULL OR r.published_at > $1`)
+
+	rows, errFA := fetchAll(pt.tx, draftRecord{}, strings.Join(queries, " UNION ALL "), at)
+	if errFA != nil {
+		return nil, errFA
+	}
+
+	return rows.([]draftRecord), nil
+}
+
+func (pt pgTx) publish(at time.Time) ([]draftRecord, error) {
+	queries := make([]string, 0, len(publishable)+1)
+	for _, table := range publishable {
+		queries = append(queries, `UPDATE `+table+` SET published_at=$1 `+
+			`WHERE published_at IS NULL AND deleted_at IS NULL RETURNING '`+table+`', ext_id`)
+	}
+
+	queries = append(queries, `UPDATE recommendation r SET published_at=$1 FROM district d `+
+		`WHERE d.int_id=r.district AND r.published_at IS NULL RETURNING 'recommendation', d.ext_id`)
+
+	var published []draftRecord
+
+	for _, query := range queries {
+		rows, errFA := fetchAll(pt.tx, draftRecord{}, query, at)
+		if errFA != nil {
+			return nil, errFA
+		}
+
+		published = append(published, rows.([]draftRecord)...)
+	}
+
+	return published, nil
+}
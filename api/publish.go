@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"sort"
+	"time"
+)
+
+// publicTx hides drafts from the lookups of a read-only transaction.
+type publicTx struct {
+	storeTx
+
+	hidden map[draftRecord]struct{}
+}
+
+func newPublicTx(tx storeTx) (publicTx, error) {
+	drafts, errDr := tx.drafts(time.Now())
+	if errDr != nil {
+		return publicTx{}, errDr
+	}
+
+	res := publicTx{tx, make(map[draftRecord]struct{}, len(drafts))}
+	for _, draft := range drafts {
+		res.hidden[draft] = struct{}{}
+	}
+
+	return res, nil
+}
+
+func (pt publicTx) published(kind string, id uuid.UUID) bool {
+	_, draft := pt.hidden[draftRecord{kind, id}]
+	return !draft
+}
+
+func (pt publicTx) states() ([]stateRecord, error) {
+	rows, errSt := pt.storeTx.states()
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("state", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errSt
+}
+
+func (pt publicTx) state(id uuid.UUID) (stateRecord, bool, error) {
+	row, ok, errSt := pt.storeTx.state(id)
+	return row, ok && pt.published("state", row.Id), errSt
+}
+
+func (pt publicTx) stateByCode(code string) (stateRecord, bool, error) {
+	row, ok, errSt := pt.storeTx.stateByCode(code)
+	return row, ok && pt.published("state", row.Id), errSt
+}
+
+func (pt publicTx) publishedOffices(rows []officeRecord, errOs error) ([]officeRecord, error) {
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("office", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errOs
+}
+
+func (pt publicTx) offices(state uuid.UUID, subdivision, city string) ([]officeRecord, error) {
+	return pt.publishedOffices(pt.storeTx.offices(state, subdivision, city))
+}
+
+func (pt publicTx) locatedOffices() ([]officeRecord, error) {
+	return pt.publishedOffices(pt.storeTx.locatedOffices())
+}
+
+func (pt publicTx) office(id uuid.UUID) (officeRecord, bool, error) {
+	row, ok, errOf := pt.storeTx.office(id)
+	return row, ok && pt.published("office", row.Id), errOf
+}
+
+func (pt publicTx) publishedStations(rows []stationRecord, errSs error) ([]stationRecord, error) {
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("station", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errSs
+}
+
+func (pt publicTx) stations(office, election uuid.UUID) ([]stationRecord, error) {
+	return pt.publishedStations(pt.storeTx.stations(office, election))
+}
+
+func (pt publicTx) officeStations(office uuid.UUID) ([]stationRecord, error) {
+	return pt.publishedStations(pt.storeTx.officeStations(office))
+}
+
+func (pt publicTx) districtStations(district uuid.UUID) ([]stationRecord, error) {
+	return pt.publishedStations(pt.storeTx.districtStations(district))
+}
+
+func (pt publicTx) station(id uuid.UUID) (stationRecord, bool, error) {
+	row, ok, errSt := pt.storeTx.station(id)
+	return row, ok && pt.published("station", row.Id), errSt
+}
+
+func (pt publicTx) districts(election uuid.UUID) ([]districtRecord, error) {
+	rows, errDs := pt.storeTx.districts(election)
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("district", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errDs
+}
+
+func (pt publicTx) district(id uuid.UUID) (districtRecord, bool, error) {
+	row, ok, errDs := pt.storeTx.district(id)
+	return row, ok && pt.published("district", row.Id), errDs
+}
+
+func (pt publicTx) candidates(district uuid.UUID) ([]candidateRecord, error) {
+	rows, errCs := pt.storeTx.candidates(district)
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("candidate", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errCs
+}
+
+func (pt publicTx) candidate(id uuid.UUID) (candidateRecord, bool, error) {
+	row, ok, errCd := pt.storeTx.candidate(id)
+	return row, ok && pt.published("candidate", row.Id), errCd
+}
+
+func (pt publicTx) recommendation(district uuid.UUID) (recommendationRecord, bool, error) {
+	row, ok, errRc := pt.storeTx.recommendation(district)
+	return row, ok && pt.published("recommendation", district), errRc
+}
+
+func (pt publicTx) elections() ([]electionRecord, error) {
+	rows, errEs := pt.storeTx.elections()
+	res := rows[:0:0]
+
+	for _, row := range rows {
+		if pt.published("election", row.Id) {
+			res = append(res, row)
+		}
+	}
+
+	return res, errEs
+}
+
+func (pt publicTx) election(id uuid.UUID) (electionRecord, bool, error) {
+	row, ok, errEl := pt.storeTx.election(id)
+	return row, ok && pt.published("election", row.Id), errEl
+}
+
+// latestElection falls back to the published election voting most recently if the latest one is a draft.
+// Unlike the store's, it doesn't order elections voting at the same time by creation.
+func (pt publicTx) latestElection() (electionRecord, bool, error) {
+	latest, ok, errLE := pt.storeTx.latestElection()
+	if errLE != nil || !ok || pt.published("election", latest.Id) {
+		return latest, ok, errLE
+	}
+
+	elections, errEs := pt.elections()
+	if errEs != nil || len(elections) < 1 {
+		return electionRecord{}, false, errEs
+	}
+
+	sort.Slice(elections, func(i, j int) bool {
+		return elections[i].VotingFrom.After(elections[j].VotingFrom)
+	})
+
+	return elections[0], true, nil
+}
+
+// draftsParam parses ?include=drafts which requires at least the editor role.
+// Unless it returns ok, it has already responded to the request.
+func draftsParam(ctx iris.Context) (drafts bool, ok bool) {
+	switch ctx.URLParam("include") {
+	case "":
+		return false, true
+	case "drafts":
+		_, ok = authorize(ctx, "editor", "drafts:read")
+		return ok, ok
+	default:
+		respondError(ctx, apiError{code: "validation.include_invalid", field: "include"})
+		return false, false
+	}
+}
+
+// doPublicTx runs f in a read-only transaction which hides drafts unless requested.
+func doPublicTx(drafts bool, f func(tx storeTx) error) error {
+	return doTx(true, func(tx storeTx) error {
+		if drafts {
+			return f(tx)
+		}
+
+		public, errNP := newPublicTx(tx)
+		if errNP != nil {
+			return errNP
+		}
+
+		return f(public)
+	})
+}
+
+func postPublish(ctx iris.Context) {
+	var payload struct {
+		// At schedules the publication, it defaults to now.
+		At *time.Time `json:"at"`
+	}
+
+	body, errGB := ctx.GetBody()
+	if errGB != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errGB.Error()})
+		return
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		if errUm := json.Unmarshal(body, &payload); errUm != nil {
+			respondError(ctx, apiError{"validation.malformed_json", "", errUm.Error()})
+			return
+		}
+	}
+
+	if payload.At == nil {
+		now := time.Now()
+		payload.At = &now
+	}
+
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+	var published []draftRecord
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errPb error
+			if published, errPb = tx.publish(*payload.At); errPb != nil || len(published) < 1 {
+				return errPb
+			}
+
+			if errBR := tx.bumpRevision(); errBR != nil {
+				return errBR
+			}
+
+			for _, record := range published {
+				if errLC := logChange(tx, record.Kind, record.Id); errLC != nil {
+					return errLC
+				}
+			}
+
+			after, errMs := json.Marshal(map[string]interface{}{"at": *payload.At, "published": len(published)})
+			if errMs != nil {
+				return errMs
+			}
+
+			return recordAudit(tx, p, "publish", "publication", uid, nil, after)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	ctx.JSON(struct {
+		At        time.Time `json:"at"`
+		Published int       `json:"published"`
+	}{*payload.At, len(published)})
+}
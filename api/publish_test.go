@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishDrafts(t *testing.T) {
+	anon, admin := newTestApi(t)
+	_, viewer := newTestAccount(anon, admin, "viewer", "viewer")
+
+	state := admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).
+		Expect().Status(201).JSON().Object().Value("id").String().Raw()
+	offices := "/v1/states/" + state + "/offices"
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Empty()
+	anon.GET(offices).Expect().Status(404)
+
+	anon.GET("/v1/states").WithQuery("include", "drafts").Expect().Status(401)
+	viewer.GET("/v1/states").WithQuery("include", "drafts").Expect().Status(403)
+	admin.GET("/v1/states").WithQuery("include", "all").Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.include_invalid")
+
+	admin.GET("/v1/states").WithQuery("include", "drafts").Expect().Status(200).JSON().Object().ContainsKey(state)
+
+	admin.POST("/v1/publish").Expect().Status(200).JSON().Object().ValueEqual("published", 1)
+	admin.POST("/v1/publish").Expect().Status(200).JSON().Object().ValueEqual("published", 0)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().ContainsKey(state)
+	anon.GET(offices).Expect().Status(200).JSON().Object().Empty()
+
+	// Edits of published records go live right away.
+	admin.POST("/v1/states/" + state).WithJSON(map[string]string{"ru_name": "ФРГ"}).Expect().Status(204)
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().ValueEqual(state, "ФРГ")
+
+	events := admin.GET("/v1/audit").Expect().Status(200).JSON().Array()
+	events.Length().Equal(4)
+	events.Element(2).Object().ValueEqual("action", "publish").ValueEqual("entity_type", "publication")
+}
+
+func TestPublishScheduled(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).
+		Expect().Status(201).JSON().Object().Value("id").String().Raw()
+
+	admin.POST("/v1/publish").WithJSON(map[string]interface{}{"at": time.Now().Add(time.Hour)}).
+		Expect().Status(200).JSON().Object().ValueEqual("published", 1)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Empty()
+	admin.GET("/v1/states").WithQuery("include", "drafts").Expect().Status(200).JSON().Object().ContainsKey(state)
+
+	admin.POST("/v1/publish").WithText("{").WithHeader("Content-Type", "application/json").
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.malformed_json")
+}
+
+func TestPublishUpdatedRecommendation(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	var candidates []string
+	for _, name := range []string{"Иванов Иван Иванович", "Петров Пётр Петрович"} {
+		candidates = append(candidates, create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+			"ru_name": name, "party": "Самовыдвижение", "status": "registered",
+		}))
+	}
+
+	recommendation := "/v1/stations/" + station + "/recommendation"
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").
+		WithJSON(map[string]string{"candidate": candidates[0]}).Expect().Status(204)
+	anon.GET(recommendation).Expect().Status(404)
+
+	admin.POST("/v1/publish").Expect().Status(200)
+	anon.GET(recommendation).Expect().Status(200).JSON().Object().
+		Value("candidate").Object().ValueEqual("id", candidates[0])
+
+	// Like edits of other published records, replacements go live right away.
+	admin.PUT("/v1/districts/" + district + "/recommendation").
+		WithJSON(map[string]string{"candidate": candidates[1]}).Expect().Status(204)
+	anon.GET(recommendation).Expect().Status(200).JSON().Object().
+		Value("candidate").Object().ValueEqual("id", candidates[1])
+
+	// Unlike recreations after deletions.
+	admin.DELETE("/v1/districts/" + district + "/recommendation").Expect().Status(204)
+	admin.PUT("/v1/districts/" + district + "/recommendation").
+		WithJSON(map[string]string{"candidate": candidates[0]}).Expect().Status(204)
+	anon.GET(recommendation).Expect().Status(404)
+}
+
+func TestPublishRecommendedDraftCandidate(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	published := create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": published}).
+		Expect().Status(204)
+	admin.POST("/v1/publish").Expect().Status(200)
+
+	draft := admin.PUT("/v1/districts/" + district + "/candidates").WithJSON(map[string]string{
+		"ru_name": "Петров Пётр Петрович", "party": "Самовыдвижение", "status": "registered",
+	}).Expect().Status(201).JSON().Object().Value("id").String().Raw()
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": draft}).
+		Expect().Status(204)
+
+	// The draft candidate doesn't leak through the published recommendation.
+	anon.GET("/v1/candidates/" + draft).Expect().Status(404)
+	anon.GET("/v1/stations/" + station + "/recommendation").Expect().Status(404)
+	anon.GET("/v1/offices/"+office+"/recommendations").Expect().Status(200).JSON().Object().
+		Value(district).Object().ValueEqual("recommendation", nil)
+
+	admin.GET("/v1/stations/"+station+"/recommendation").WithQuery("include", "drafts").Expect().Status(200).
+		JSON().Object().Value("candidate").Object().ValueEqual("id", draft)
+
+	admin.POST("/v1/publish").Expect().Status(200)
+	anon.GET("/v1/stations/"+station+"/recommendation").Expect().Status(200).JSON().Object().
+		Value("candidate").Object().ValueEqual("id", draft)
+}
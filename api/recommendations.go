@@ -0,0 +1,315 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"time"
+)
+
+type recommendedCandidate struct {
+	Id     uuid.UUID `json:"id"`
+	RuName string    `json:"ru_name"`
+	Name   string    `json:"name"`
+	Party  string    `json:"party"`
+}
+
+type recommendation struct {
+	Candidate recommendedCandidate `json:"candidate"`
+	Note      string               `json:"note"`
+	Published time.Time            `json:"published"`
+}
+
+func putRecommendation(ctx iris.Context) {
+	var payload struct {
+		Candidate uuid.UUID  `json:"candidate"`
+		Note      string     `json:"note"`
+		Published *time.Time `json:"published"`
+	}
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	if payload.Candidate == uuid.Nil {
+		respondError(ctx, apiError{code: "validation.candidate_missing", field: "candidate"})
+		return
+	}
+
+	if payload.Published == nil {
+		now := time.Now()
+		payload.Published = &now
+	}
+
+	p := principalOf(ctx)
+	var foundDistrict, foundCandidate bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errLU error
+			if _, foundDistrict, errLU = tx.district(extId); errLU != nil || !foundDistrict {
+				return errLU
+			}
+
+			candidate, ok, errCd := tx.candidate(payload.Candidate)
+			if errCd != nil {
+				return errCd
+			}
+
+			if foundCandidate = ok && candidate.District == extId; !foundCandidate {
+				return nil
+			}
+
+			return audited(tx, p, "recommendation", extId, func() error {
+				return tx.putRecommendation(recommendationRecord{
+					extId, payload.Candidate, payload.Note, *payload.Published,
+				})
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if foundDistrict {
+		if foundCandidate {
+			ctx.StatusCode(204)
+		} else {
+			respondError(ctx, apiError{code: "not_found.candidate_in_district", field: "candidate"})
+		}
+	} else {
+		respondError(ctx, apiError{code: "not_found.district"})
+	}
+}
+
+func deleteRecommendation(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "recommendation", extId, versions); errCV != nil || !current {
+				return errCV
+			}
+
+			return audited(tx, p, "recommendation", extId, func() error {
+				var errDR error
+				found, errDR = tx.deleteRecommendation(extId)
+				return errDR
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.recommendation"})
+	}
+}
+
+// publishedRecommendation returns the recommendation for district if already published, otherwise nil.
+// One of a candidate not visible, e.g. a draft, counts as not published. The candidate's name isn't localized yet.
+func publishedRecommendation(tx storeTx, district uuid.UUID) (*recommendation, error) {
+	rec, ok, errRc := tx.recommendation(district)
+	if errRc != nil || !ok || rec.Published.After(time.Now()) {
+		return nil, errRc
+	}
+
+	candidate, ok, errCd := tx.candidate(rec.Candidate)
+	if errCd != nil || !ok {
+		return nil, errCd
+	}
+
+	return &recommendation{
+		recommendedCandidate{candidate.Id, candidate.RuName, candidate.RuName, candidate.Party},
+		rec.Note, rec.Published,
+	}, nil
+}
+
+// localizeRecommendations localizes the candidates' names of recs.
+func localizeRecommendations(tx storeTx, prefs []string, recs ...*recommendation) error {
+	localized := map[uuid.UUID]string{}
+
+	for _, rec := range recs {
+		if rec != nil {
+			localized[rec.Candidate.Id] = rec.Candidate.RuName
+		}
+	}
+
+	if errLc := localize(tx, prefs, localized); errLc != nil {
+		return errLc
+	}
+
+	for _, rec := range recs {
+		if rec != nil {
+			rec.Candidate.Name = localized[rec.Candidate.Id]
+		}
+	}
+
+	return nil
+}
+
+func getStationRecommendation(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var found bool
+	var station stationRecord
+	var rec *recommendation
+	var version int64
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errSt error
+			if station, found, errSt = tx.station(extId); errSt != nil || !found {
+				return errSt
+			}
+
+			var errPR error
+			if rec, errPR = publishedRecommendation(tx, station.District); errPR != nil || rec == nil {
+				return errPR
+			}
+
+			if version, _, errPR = tx.version("recommendation", station.District); errPR != nil {
+				return errPR
+			}
+
+			return localizeRecommendations(tx, prefs, rec)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !found {
+		respondError(ctx, apiError{code: "not_found.station"})
+	} else if rec == nil {
+		respondError(ctx, apiError{code: "not_found.recommendation"})
+	} else {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			District uuid.UUID `json:"district"`
+			recommendation
+		}{station.District, *rec})
+	}
+}
+
+func getOfficeRecommendations(ctx iris.Context) {
+	type district struct {
+		Stations       []uuid.UUID     `json:"stations"`
+		Recommendation *recommendation `json:"recommendation"`
+	}
+
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var foundElection, found bool
+	var res map[uuid.UUID]*district
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
+			}
+
+			if foundElection = ok; !foundElection {
+				return nil
+			}
+
+			var errOf error
+			if _, found, errOf = tx.office(extId); errOf != nil || !found {
+				return errOf
+			}
+
+			stations, errSs := tx.stations(extId, electionId)
+			if errSs != nil {
+				return errSs
+			}
+
+			res = map[uuid.UUID]*district{}
+			var recs []*recommendation
+
+			for _, row := range stations {
+				d, ok := res[row.District]
+				if !ok {
+					d = &district{}
+
+					var errPR error
+					if d.Recommendation, errPR = publishedRecommendation(tx, row.District); errPR != nil {
+						return errPR
+					}
+
+					recs = append(recs, d.Recommendation)
+					res[row.District] = d
+				}
+
+				d.Stations = append(d.Stations, row.Id)
+			}
+
+			return localizeRecommendations(tx, prefs, recs...)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !foundElection {
+		respondError(ctx, apiError{code: "not_found.election"})
+	} else if found {
+		ctx.JSON(res)
+	} else {
+		respondError(ctx, apiError{code: "not_found.office"})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestRecommendationsRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	election := newTestElection(admin)
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	candidate := create(admin, "/v1/districts/"+district+"/candidates", map[string]interface{}{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+		"names": map[string]string{"en": "Ivan Ivanov"},
+	})
+
+	anon.GET("/v1/stations/"+station+"/recommendation").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.recommendation")
+
+	anon.GET("/v1/offices/"+office+"/recommendations").Expect().Status(200).JSON().Object().
+		Value(district).Object().ValueEqual("recommendation", nil)
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{
+		"candidate": candidate, "note": "Единственный кандидат",
+	}).Expect().Status(204)
+
+	publishDrafts()
+
+	rec := anon.GET("/v1/stations/"+station+"/recommendation").WithHeader("Accept-Language", "en").
+		Expect().Status(200).JSON().Object()
+
+	rec.ValueEqual("district", district).ValueEqual("note", "Единственный кандидат")
+	rec.Value("candidate").Object().ValueEqual("id", candidate).ValueEqual("name", "Ivan Ivanov")
+
+	recs := anon.GET("/v1/elections/" + election + "/offices/" + office + "/recommendations").
+		Expect().Status(200).JSON().Object().Value(district).Object()
+
+	recs.Value("stations").Array().Elements(station)
+	recs.Value("recommendation").Object().Value("candidate").Object().ValueEqual("id", candidate)
+
+	admin.DELETE("/v1/districts/" + district + "/recommendation").Expect().Status(204)
+	admin.DELETE("/v1/districts/" + district + "/recommendation").Expect().Status(404)
+
+	anon.GET("/v1/stations/" + station + "/recommendation").Expect().Status(404)
+}
+
+func TestRecommendationsUnpublished(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	candidate := create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]interface{}{
+		"candidate": candidate, "published": time.Now().Add(time.Hour),
+	}).Expect().Status(204)
+
+	anon.GET("/v1/stations/" + station + "/recommendation").Expect().Status(404)
+
+	anon.GET("/v1/offices/"+office+"/recommendations").Expect().Status(200).JSON().Object().
+		Value(district).Object().ValueEqual("recommendation", nil)
+}
+
+func TestRecommendationsNotFound(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district1 := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	district2 := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №2"})
+
+	candidate := create(admin, "/v1/districts/"+district2+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+
+	missing := uuid.New().String()
+
+	admin.PUT("/v1/districts/"+district1+"/recommendation").WithJSON(map[string]string{}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.candidate_missing")
+
+	admin.PUT("/v1/districts/"+district1+"/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.candidate_in_district")
+
+	admin.PUT("/v1/districts/"+missing+"/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.district")
+
+	anon.GET("/v1/stations/"+missing+"/recommendation").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.station")
+
+	anon.GET("/v1/offices/"+missing+"/recommendations").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.office")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// privateKinds are the kinds of records never served to the public, changing them doesn't bump the revision.
+var privateKinds = map[string]struct{}{"account": {}, "token": {}}
+
+// revisioned serves the data revision as ETag and Last-Modified of public lists and answers
+// a matching If-None-Match with 304. The ETag also changes once anything scheduled is published
+// and depends on the languages asked for. Drafts included on request aren't taken into account.
+func revisioned(ctx iris.Context) {
+	if ctx.URLParamExists("include") {
+		ctx.Next()
+		return
+	}
+
+	var rev revisionRecord
+
+	errTx := doTx(true, func(tx storeTx) error {
+		var errRv error
+		rev, errRv = tx.revision(time.Now())
+		return errRv
+	})
+	if errTx != nil {
+		respondInternal(ctx, errTx)
+		return
+	}
+
+	etag := fmt.Sprintf(`W/"%d.%d.%s"`, rev.Revision, rev.Modified.UnixNano(), strings.Join(langPrefs(ctx), "+"))
+
+	ctx.Header("ETag", etag)
+	ctx.Header("Last-Modified", rev.Modified.UTC().Format(http.TimeFormat))
+
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.StatusCode(304)
+		return
+	}
+
+	ctx.Next()
+}
+
+// etagMatches tells whether any entity tag of an If-None-Match header weakly matches etag.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionTag is the ETag of a single record's version served in the languages langs (see langPrefs).
+func versionTag(version int64, langs []string) string {
+	tag := strconv.FormatInt(version, 10)
+	if len(langs) > 0 {
+		tag += "." + strings.Join(langs, "+")
+	}
+
+	return `"` + tag + `"`
+}
+
+// versionParam parses If-Match into the acceptable versions, nil if any. It responds itself on failure.
+// The languages of the tags don't matter, all representations of a version are as current.
+func versionParam(ctx iris.Context) ([]int64, bool) {
+	raw := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if raw == "" || raw == "*" {
+		return nil, true
+	}
+
+	var res []int64
+
+	for _, tag := range strings.Split(raw, ",") {
+		var number string
+		if tag = strings.TrimSpace(tag); len(tag) > 1 && tag[0] == '"' && tag[len(tag)-1] == '"' {
+			number = strings.SplitN(tag[1:len(tag)-1], ".", 2)[0]
+		}
+
+		version, errPI := strconv.ParseInt(number, 10, 64)
+		if errPI != nil || strconv.FormatInt(version, 10) != number {
+			respondError(ctx, apiError{code: "validation.if_match_invalid"})
+			return nil, false
+		}
+
+		res = append(res, version)
+	}
+
+	return res, true
+}
+
+// checkVersion tells whether the record id of kind is of any of the versions, see versionParam.
+// It's true for a missing record not to get in the way of a 404.
+func checkVersion(tx storeTx, kind string, id uuid.UUID, versions []int64) (bool, error) {
+	if versions == nil {
+		return true, nil
+	}
+
+	version, found, errVe := tx.version(kind, id)
+	if errVe != nil || !found {
+		return true, errVe
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
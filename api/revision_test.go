@@ -0,0 +1,155 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestRevisionConditionalGets(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	offices := "/v1/states/" + state + "/offices"
+
+	resp := anon.GET("/v1/states").Expect().Status(200)
+	etag := resp.Header("ETag").NotEmpty().Raw()
+	resp.Header("Last-Modified").NotEmpty()
+
+	anon.GET("/v1/states").WithHeader("If-None-Match", etag).Expect().Status(304).Body().Empty()
+	anon.GET(offices).WithHeader("If-None-Match", `"other", `+etag).Expect().Status(304)
+	anon.GET("/v1/states").WithHeader("If-None-Match", "*").Expect().Status(304)
+
+	// Languages make a difference.
+	anon.GET("/v1/states").WithHeader("If-None-Match", etag).WithHeader("Accept-Language", "en").
+		Expect().Status(200).Header("ETag").NotEqual(etag)
+
+	multilingual := anon.GET("/v1/states").WithHeader("Accept-Language", "en, de").Expect().Header("ETag").Raw()
+	anon.GET("/v1/states").WithHeader("If-None-Match", multilingual).WithHeader("Accept-Language", "en, de").
+		Expect().Status(304)
+
+	// So does any write.
+	admin.POST("/v1/states/" + state).WithJSON(map[string]string{"ru_name": "ФРГ"}).Expect().Status(204)
+
+	resp = anon.GET("/v1/states").WithHeader("If-None-Match", etag).Expect().Status(200)
+	resp.JSON().Object().ValueEqual(state, "ФРГ")
+	resp.Header("ETag").NotEqual(etag)
+
+	// Drafts included on request are always served in full.
+	etag = anon.GET("/v1/states").Expect().Status(200).Header("ETag").Raw()
+	admin.GET("/v1/states").WithQuery("include", "drafts").WithHeader("If-None-Match", etag).
+		Expect().Status(200).Header("ETag").Empty()
+}
+
+func TestRevisionOnlyDataChanges(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	etag := anon.GET("/v1/states").Expect().Status(200).Header("ETag").Raw()
+
+	// Neither failed writes nor authentication, accounts or updates to the same values bump it.
+	anon.GET("/v1/audit").WithHeader("Authorization", "Bearer vt_nonsense").Expect().Status(401)
+	admin.POST("/v1/states/" + uuid.New().String()).WithJSON(map[string]string{"ru_name": "ФРГ"}).
+		Expect().Status(404)
+	admin.POST("/v1/states/"+state).WithHeader("If-Match", `"7"`).WithJSON(map[string]string{"ru_name": "ФРГ"}).
+		Expect().Status(412)
+	admin.POST("/v1/states/" + state).WithJSON(map[string]string{"ru_name": "Германия"}).Expect().Status(204)
+	newTestAccount(anon, admin, "editor", "editor")
+
+	anon.GET("/v1/states").WithHeader("If-None-Match", etag).Expect().Status(304)
+}
+
+func TestRevisionScheduledPublication(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).Expect().Status(201)
+	admin.POST("/v1/publish").WithJSON(map[string]interface{}{"at": time.Now().Add(time.Second / 2)}).
+		Expect().Status(200)
+
+	etag := anon.GET("/v1/states").Expect().Status(200).Header("ETag").Raw()
+	anon.GET("/v1/states").WithHeader("If-None-Match", etag).Expect().Status(304)
+
+	time.Sleep(time.Second / 2)
+
+	anon.GET("/v1/states").WithHeader("If-None-Match", etag).Expect().Status(200).JSON().Object().
+		Keys().Length().Equal(1)
+}
+
+func TestRevisionIfMatch(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	rename := map[string]string{"ru_name": "Посольство России в Берлине"}
+
+	etag := anon.GET("/v1/offices/" + office).Expect().Status(200).Header("ETag").Equal(`"1"`).Raw()
+
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", etag).WithJSON(rename).Expect().Status(204)
+
+	// The other coordinator is too late.
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", etag).WithJSON(rename).
+		Expect().Status(412).JSON().Object().ValueEqual("code", "precondition_failed.version")
+	admin.DELETE("/v1/offices/"+office).WithHeader("If-Match", etag).Expect().Status(412)
+
+	etag = anon.GET("/v1/offices/" + office).Expect().Status(200).Header("ETag").Equal(`"2"`).Raw()
+
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", `"7", `+etag).WithJSON(rename).Expect().Status(204)
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", "*").WithJSON(rename).Expect().Status(204)
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", `W/"4"`).WithJSON(rename).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.if_match_invalid")
+
+	// Every language has its own tag, but they're all as current.
+	admin.GET("/v1/offices/" + office).Expect().Status(200).Header("ETag").Equal(`"4"`)
+	etag = anon.GET("/v1/offices/"+office).WithHeader("Accept-Language", "en, de").Expect().Status(200).
+		Header("ETag").Equal(`"4.en+de"`).Raw()
+	anon.GET("/v1/offices/" + office).Expect().Status(200).Header("Vary").Equal("Accept-Language")
+
+	admin.POST("/v1/offices/"+office).WithHeader("If-Match", `"1.en", `+etag).WithJSON(rename).Expect().Status(204)
+
+	admin.DELETE("/v1/offices/"+office).WithHeader("If-Match", `"5"`).Expect().Status(204)
+	admin.DELETE("/v1/offices/"+office).WithHeader("If-Match", `"5"`).Expect().Status(404)
+
+	// Other records keep their versions.
+	anon.GET("/v1/states/" + state).Expect().Status(200).Header("ETag").Equal(`"1"`)
+}
+
+func TestRevisionIfMatchAllKinds(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	election := newTestElection(admin)
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	candidate := create(admin, "/v1/districts/"+district+"/candidates", map[string]string{
+		"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "registered",
+	})
+	accountId, _ := newTestAccount(anon, admin, "editor", "editor")
+
+	for path, payload := range map[string]interface{}{
+		"/v1/elections/" + election: map[string]string{
+			"ru_name": "Выборы", "voting_from": "2021-09-17", "voting_to": "2021-09-19", "status": "active",
+		},
+		"/v1/districts/" + district: map[string]string{"ru_name": "Округ №2"},
+		"/v1/candidates/" + candidate: map[string]string{
+			"ru_name": "Иванов Иван Иванович", "party": "Самовыдвижение", "status": "withdrawn",
+		},
+		"/v1/accounts/" + accountId: map[string]string{"name": "editor", "role": "viewer"},
+	} {
+		admin.GET(path).Expect().Status(200).Header("ETag").Equal(`"1"`)
+		admin.POST(path).WithHeader("If-Match", `"1"`).WithJSON(payload).Expect().Status(204)
+		admin.POST(path).WithHeader("If-Match", `"1"`).WithJSON(payload).Expect().Status(412)
+		admin.GET(path).Expect().Status(200).Header("ETag").Equal(`"2"`)
+	}
+
+	anon.GET("/v1/candidates/"+candidate).Expect().Status(200).JSON().Object().
+		ValueEqual("district", district).ValueEqual("status", "withdrawn")
+	anon.GET("/v1/districts/"+district).Expect().Status(200).JSON().Object().ValueEqual("election", election)
+	anon.GET("/v1/elections/"+election).Expect().Status(200).JSON().Object().ValueEqual("current", true)
+	anon.GET("/v1/accounts/" + accountId).Expect().Status(401)
+	admin.GET("/v1/accounts/"+accountId).Expect().Status(200).JSON().Object().ValueEqual("role", "viewer")
+
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(204)
+	admin.PUT("/v1/districts/" + district + "/recommendation").WithJSON(map[string]string{"candidate": candidate}).
+		Expect().Status(204)
+	admin.DELETE("/v1/districts/"+district+"/recommendation").WithHeader("If-Match", `"1"`).Expect().Status(412)
+	admin.DELETE("/v1/districts/"+district+"/recommendation").WithHeader("If-Match", `"2"`).Expect().Status(204)
+}
@@ -1,49 +1,86 @@
 package main
 
 import (
-	"database/sql"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
-	log "github.com/sirupsen/logrus"
 	"strings"
 )
 
-func putStates(ctx iris.Context) {
-	var payload struct {
-		RuName string `json:"ru_name"`
+type statePayload struct {
+	RuName  string            `json:"ru_name"`
+	IsoCode *string           `json:"iso_code"`
+	Names   map[string]string `json:"names"`
+}
+
+// isoCodeTaken tells whether a state other than extId already has code.
+func isoCodeTaken(tx storeTx, code *string, extId uuid.UUID) (bool, error) {
+	if code == nil {
+		return false, nil
 	}
 
+	other, found, errSC := tx.stateByCode(*code)
+	return found && other.Id != extId, errSC
+}
+
+func putStates(ctx iris.Context) {
+	var payload statePayload
+
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := validateIsoCode(payload.IsoCode); invalid != nil {
+		respondError(ctx, *invalid)
 		return
 	}
 
 	uid, errNR := uuid.NewRandom()
 	if errNR != nil {
-		ctx.StatusCode(500)
-		ctx.JSON(errorResponse{errNR.Error()})
+		respondInternal(ctx, errNR)
 		return
 	}
 
+	p := principalOf(ctx)
+	var taken bool
+
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			_, errEx := tx.Exec(`INSERT INTO state(ext_id, ru_name) VALUES ($1, $2)`, uid, payload.RuName)
-			return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCT error
+			if taken, errCT = isoCodeTaken(tx, payload.IsoCode, uid); errCT != nil || taken {
+				return errCT
+			}
+
+			return audited(tx, p, "state", uid, func() error {
+				if errPS := tx.putState(stateRecord{uid, payload.RuName, payload.IsoCode}); errPS != nil {
+					return errPS
+				}
+
+				return putNames(tx, uid, names)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
+	if taken {
+		respondError(ctx, apiError{code: "conflict.iso_code_taken", field: "iso_code"})
+		return
+	}
+
 	ctx.StatusCode(201)
 	ctx.JSON(struct {
 		Id uuid.UUID `json:"id"`
@@ -51,122 +88,276 @@ func putStates(ctx iris.Context) {
 }
 
 func getStates(ctx iris.Context) {
-	type row struct {
-		ExtId  uuid.UUID
-		RuName string
-	}
-
-	rawRows, errFA := fetchAll(db, row{}, "SELECT ext_id, ru_name FROM state")
-	if errFA != nil {
-		log.WithFields(log.Fields{"error": errFA.Error()}).Error("Query error")
-		ctx.StatusCode(500)
+	drafts, ok := draftsParam(ctx)
+	if !ok {
 		return
 	}
 
-	rows := rawRows.([]row)
-	res := make(map[uuid.UUID]string, len(rows))
+	prefs := langPrefs(ctx)
+	var res map[uuid.UUID]string
+
+	errTx := doPublicTx(drafts, func(tx storeTx) error {
+		rows, errSt := tx.states()
+		if errSt != nil {
+			return errSt
+		}
 
-	for _, row := range rows {
-		res[row.ExtId] = row.RuName
+		res = make(map[uuid.UUID]string, len(rows))
+
+		for _, row := range rows {
+			res[row.Id] = row.RuName
+		}
+
+		return localize(tx, prefs, res)
+	})
+	if errTx != nil {
+		respondInternal(ctx, errTx)
+		return
 	}
 
 	_, _ = ctx.JSON(res)
 }
 
 func postStates(ctx iris.Context) {
-	var payload struct {
-		RuName string `json:"ru_name"`
-	}
+	var payload statePayload
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
 		return
 	}
 
-	var found bool
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := validateIsoCode(payload.IsoCode); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found, taken bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`UPDATE state SET ru_name=$1 WHERE ext_id=$2`, payload.RuName, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "state", extId, versions); errCV != nil || !current {
+				return errCV
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			var errCT error
+			if taken, errCT = isoCodeTaken(tx, payload.IsoCode, extId); errCT != nil || taken {
+				return errCT
 			}
 
-			found = rows > 0
+			return audited(tx, p, "state", extId, func() error {
+				ok, errUS := tx.updateState(stateRecord{extId, payload.RuName, payload.IsoCode})
+				if errUS != nil {
+					return errUS
+				}
 
-			return nil
+				if found = ok; !found {
+					return nil
+				}
+
+				return putNames(tx, extId, names)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if found {
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if taken {
+		respondError(ctx, apiError{code: "conflict.iso_code_taken", field: "iso_code"})
+	} else if found {
 		ctx.StatusCode(204)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such state"})
+		respondError(ctx, apiError{code: "not_found.state"})
 	}
 }
 
-func deleteStates(ctx iris.Context) {
+func getState(ctx iris.Context) {
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var state stateRecord
+	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	errTx := doPublicTx(drafts, func(tx storeTx) error {
+		var errSt error
+		if state, found, errSt = tx.state(extId); errSt != nil || !found {
+			return errSt
+		}
+
+		if version, _, errSt = tx.version("state", extId); errSt != nil {
+			return errSt
+		}
+
+		name = map[uuid.UUID]string{state.Id: state.RuName}
+		return localize(tx, prefs, name)
+	})
+	if errTx != nil {
+		respondInternal(ctx, errTx)
+		return
+	}
+
+	if !found {
+		respondError(ctx, apiError{code: "not_found.state"})
+		return
+	}
+
+	ctx.Header("ETag", versionTag(version, prefs))
+
+	_, _ = ctx.JSON(struct {
+		Id      uuid.UUID `json:"id"`
+		RuName  string    `json:"ru_name"`
+		Name    string    `json:"name"`
+		IsoCode *string   `json:"iso_code"`
+	}{state.Id, state.RuName, name[state.Id], state.IsoCode})
+}
+
+func getStateByCode(ctx iris.Context) {
+	code := ctx.Params().Get("cc")
+	if validateIsoCode(&code) != nil {
+		respondError(ctx, apiError{code: "validation.iso_code_invalid"})
+		return
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
 		return
 	}
 
+	prefs := langPrefs(ctx)
+	var state stateRecord
 	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	errTx := doPublicTx(drafts, func(tx storeTx) error {
+		var errSC error
+		if state, found, errSC = tx.stateByCode(code); errSC != nil || !found {
+			return errSC
+		}
+
+		if version, _, errSC = tx.version("state", state.Id); errSC != nil {
+			return errSC
+		}
+
+		name = map[uuid.UUID]string{state.Id: state.RuName}
+		return localize(tx, prefs, name)
+	})
+	if errTx != nil {
+		respondInternal(ctx, errTx)
+		return
+	}
+
+	if !found {
+		respondError(ctx, apiError{code: "not_found.state"})
+		return
+	}
+
+	ctx.Header("ETag", versionTag(version, prefs))
+
+	_, _ = ctx.JSON(struct {
+		Id      uuid.UUID `json:"id"`
+		RuName  string    `json:"ru_name"`
+		Name    string    `json:"name"`
+		IsoCode string    `json:"iso_code"`
+	}{state.Id, state.RuName, name[state.Id], *state.IsoCode})
+}
+
+func deleteStates(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	cascade, errCP := cascadeParam(ctx)
+	if errCP != nil {
+		respondError(ctx, apiError{code: "validation.cascade_invalid", field: "cascade"})
+		return
+	}
+
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var current, found bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`DELETE FROM state WHERE ext_id=$1`, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errCV error
+			if current, errCV = checkVersion(tx, "state", extId, versions); errCV != nil || !current {
+				return errCV
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			if cascade {
+				if errDp := deleteDependents(tx, p, extId, stateDependents); errDp != nil {
+					return errDp
+				}
 			}
 
-			found = rows > 0
-
-			return nil
+			return audited(tx, p, "state", extId, func() error {
+				var errDS error
+				found, errDS = tx.deleteState(extId)
+				return errDS
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			if errTx == errNotGranted {
+				respondError(ctx, apiError{code: "forbidden.state"})
+			} else if referenced(errTx) {
+				respondReferenced(ctx, "conflict.state_referenced", extId, stateDependents)
+			} else {
+				respondInternal(ctx, errTx)
+			}
+
 			return
 		}
 	}
 
-	if found {
+	if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if found {
 		ctx.StatusCode(204)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such state"})
+		respondError(ctx, apiError{code: "not_found.state"})
 	}
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestStatesRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Empty()
+
+	id := create(admin, "/v1/states", map[string]interface{}{
+		"ru_name": "Германия", "iso_code": "de", "names": map[string]string{"en": "Germany"},
+	})
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Equal(map[string]string{id: "Германия"})
+
+	anon.GET("/v1/states").WithHeader("Accept-Language", "en-US,en;q=0.9").Expect().
+		Status(200).Header("Vary").Equal("Accept-Language")
+
+	anon.GET("/v1/states").WithQuery("lang", "en").Expect().
+		Status(200).JSON().Object().Equal(map[string]string{id: "Germany"})
+
+	admin.POST("/v1/states/" + id).WithJSON(map[string]string{"ru_name": "ФРГ"}).Expect().Status(204)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Equal(map[string]string{id: "ФРГ"})
+
+	anon.GET("/v1/states/by-code/DE").Expect().Status(200).JSON().Object().
+		ValueEqual("id", id).ValueEqual("ru_name", "ФРГ").ValueEqual("iso_code", "DE")
+
+	admin.DELETE("/v1/states/" + id).Expect().Status(204)
+	admin.DELETE("/v1/states/" + id).Expect().Status(404)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Empty()
+	anon.GET("/v1/states/by-code/DE").Expect().Status(404)
+}
+
+func TestStatesValidation(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Атлантида", "iso_code": "XX"}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.iso_code_invalid")
+
+	admin.PUT("/v1/states").WithJSON(map[string]interface{}{
+		"ru_name": "Германия", "names": map[string]string{"ru": "Германия"},
+	}).Expect().Status(400).JSON().Object().ValueEqual("code", "validation.name_redundant")
+
+	admin.POST("/v1/states/"+uuid.New().String()).WithJSON(map[string]string{"ru_name": "Германия"}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.state")
+
+	anon.GET("/v1/states/by-code/XX").Expect().Status(400)
+}
+
+func TestStatesIsoCodeConflict(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	create(admin, "/v1/states", map[string]string{"ru_name": "Германия", "iso_code": "DE"})
+	other := create(admin, "/v1/states", map[string]string{"ru_name": "Австрия", "iso_code": "AT"})
+
+	admin.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "ФРГ", "iso_code": "DE"}).
+		Expect().Status(409)
+
+	admin.POST("/v1/states/" + other).WithJSON(map[string]string{"ru_name": "Австрия", "iso_code": "DE"}).
+		Expect().Status(409)
+
+	admin.POST("/v1/states/" + other).WithJSON(map[string]string{"ru_name": "Австрия", "iso_code": "AT"}).
+		Expect().Status(204)
+}
+
+func TestStatesWithOfficesCantBeDeleted(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	conflict := admin.DELETE("/v1/states/" + state).Expect().Status(409).JSON().Object()
+	conflict.ValueEqual("code", "conflict.state_referenced")
+	conflict.ValueEqual("dependents", map[string][]string{"offices": {office}, "stations": {station}})
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().ContainsKey(state)
+
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", "maybe").Expect().Status(400)
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", "false").Expect().Status(409)
+}
+
+func TestStatesCascade(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	other := create(admin, "/v1/states", map[string]string{"ru_name": "Австрия"})
+	offices := "/v1/states/" + state + "/offices"
+
+	for _, name := range []string{"Посольство в Берлине", "Генеральное консульство в Мюнхене"} {
+		office := create(admin, offices, map[string]string{"ru_name": name})
+		create(admin, "/v1/offices/"+office+"/stations", map[string]string{"ru_name": "УИК", "district": district})
+	}
+
+	kept := create(admin, "/v1/states/"+other+"/offices", map[string]string{"ru_name": "Посольство в Вене"})
+
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", "true").Expect().Status(204)
+
+	anon.GET("/v1/states").Expect().Status(200).JSON().Object().Keys().ContainsOnly(other)
+	anon.GET(offices).Expect().Status(404)
+	anon.GET("/v1/states/" + other + "/offices").Expect().Status(200).JSON().Object().Keys().ContainsOnly(kept)
+
+	// Nothing references the district anymore.
+	admin.DELETE("/v1/districts/" + district).Expect().Status(204)
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", "true").Expect().Status(404)
+}
+
+func TestStatesSingle(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]interface{}{
+		"ru_name": "Германия", "iso_code": "DE", "names": map[string]string{"en": "Germany"},
+	})
+
+	anon.GET("/v1/states/"+state).WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{"id": state, "ru_name": "Германия", "name": "Germany", "iso_code": "DE"})
+
+	admin.DELETE("/v1/states/" + state).Expect().Status(204)
+	anon.GET("/v1/states/"+state).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.state")
+}
+
+func TestStatesDistricts(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	election := newTestElection(admin)
+
+	district1 := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №2"})
+	germany := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	france := create(admin, "/v1/states", map[string]string{"ru_name": "Франция"})
+
+	for _, name := range []string{"Посольство в Берлине", "Генеральное консульство в Мюнхене"} {
+		office := create(admin, "/v1/states/"+germany+"/offices", map[string]string{"ru_name": name})
+		create(admin, "/v1/offices/"+office+"/stations", map[string]string{"ru_name": name, "district": district1})
+	}
+
+	anon.GET("/v1/states/" + germany + "/districts").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{district1: "Округ №1"})
+	anon.GET("/v1/elections/" + election + "/states/" + germany + "/districts").Expect().Status(200).JSON().Object().
+		Keys().ContainsOnly(district1)
+	anon.GET("/v1/states/" + france + "/districts").Expect().Status(200).JSON().Object().Empty()
+
+	anon.GET("/v1/states/"+district1+"/districts").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.state")
+	anon.GET("/v1/elections/"+germany+"/states/"+germany+"/districts").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.election")
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"database/sql"
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
 	"strings"
@@ -9,298 +9,462 @@ import (
 
 func putStations(ctx iris.Context) {
 	var payload struct {
-		RuName   string    `json:"ru_name"`
-		District uuid.UUID `json:"district"`
-	}
-
-	type office struct {
-		IntId int32
-	}
-
-	type district struct {
-		IntId int16
+		RuName   string            `json:"ru_name"`
+		District uuid.UUID         `json:"district"`
+		Names    map[string]string `json:"names"`
+		location
 	}
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
 	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
 		return
 	}
 
 	if payload.District == uuid.Nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".district missing"})
+		respondError(ctx, apiError{code: "validation.district_missing", field: "district"})
 		return
 	}
 
 	uid, errNR := uuid.NewRandom()
 	if errNR != nil {
-		ctx.StatusCode(500)
-		ctx.JSON(errorResponse{errNR.Error()})
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+	var foundOffice, allowed, foundDistrict bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			var errLU error
+			if foundOffice, allowed, errLU = mayEditOffice(tx, p, extId); errLU != nil || !allowed {
+				return errLU
+			}
+
+			if _, foundDistrict, errLU = tx.district(payload.District); errLU != nil || !foundDistrict {
+				return errLU
+			}
+
+			return audited(tx, p, "station", uid, func() error {
+				errPS := tx.putStation(stationRecord{
+					uid, extId, payload.District, payload.RuName, payload.Lat, payload.Lon, payload.Address,
+				})
+				if errPS != nil {
+					return errPS
+				}
+
+				return putNames(tx, uid, names)
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !foundOffice {
+		respondError(ctx, apiError{code: "not_found.office"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else if !foundDistrict {
+		respondError(ctx, apiError{code: "not_found.district"})
+	} else {
+		ctx.StatusCode(201)
+		ctx.JSON(struct {
+			Id uuid.UUID `json:"id"`
+		}{uid})
+	}
+}
+
+func getStations(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
 		return
 	}
 
-	var foundOffice, foundDistrict bool
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var foundElection, found bool
+	var stations []stationRecord
+	var localized map[uuid.UUID]string
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			rawOffices, errFA := fetchAll(tx, office{}, `SELECT int_id FROM office WHERE ext_id=$1`, extId)
-			if errFA != nil {
-				return errFA
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
 			}
 
-			offices := rawOffices.([]office)
-			if foundOffice = len(offices) > 0; !foundOffice {
+			if foundElection = ok; !foundElection {
 				return nil
 			}
 
-			rawDistricts, errFA := fetchAll(
-				tx, district{}, `SELECT int_id FROM district WHERE ext_id=$1`, payload.District,
-			)
-			if errFA != nil {
-				return errFA
+			_, ok, errOf := tx.office(extId)
+			if errOf != nil {
+				return errOf
 			}
 
-			districts := rawDistricts.([]district)
-			if foundDistrict = len(districts) > 0; !foundDistrict {
+			if found = ok; !found {
 				return nil
 			}
 
-			_, errEx := tx.Exec(
-				`INSERT INTO station(ext_id, office, district, ru_name) VALUES ($1, $2, $3, $4)`,
-				uid, offices[0].IntId, districts[0].IntId, payload.RuName,
-			)
-			return errEx
+			var errSs error
+			if stations, errSs = tx.stations(extId, electionId); errSs != nil {
+				return errSs
+			}
+
+			localized = make(map[uuid.UUID]string, len(stations))
+
+			for _, row := range stations {
+				localized[row.Id] = row.RuName
+			}
+
+			return localize(tx, prefs, localized)
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if foundOffice {
-		if foundDistrict {
-			ctx.StatusCode(204)
-		} else {
-			ctx.StatusCode(404)
-			ctx.JSON(errorResponse{"no such district"})
+	if !foundElection {
+		respondError(ctx, apiError{code: "not_found.election"})
+	} else if found {
+		type station struct {
+			District uuid.UUID `json:"district"`
+			RuName   string    `json:"ru_name"`
+			Name     string    `json:"name"`
+			location
+		}
+
+		res := make(map[uuid.UUID]station, len(stations))
+
+		for _, row := range stations {
+			res[row.Id] = station{
+				row.District, row.RuName, localized[row.Id], location{row.Lat, row.Lon, row.Address},
+			}
 		}
+
+		ctx.JSON(res)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such office"})
+		respondError(ctx, apiError{code: "not_found.office"})
 	}
 }
 
-func getStations(ctx iris.Context) {
-	type office struct {
-		IntId int32
+func getStation(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
 	}
 
-	type station struct {
-		ExtId    uuid.UUID
-		District uuid.UUID
-		RuName   string
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
 	}
 
+	prefs := langPrefs(ctx)
+	var station stationRecord
+	var found bool
+	var version int64
+	var name map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			var errSt error
+			if station, found, errSt = tx.station(extId); errSt != nil || !found {
+				return errSt
+			}
+
+			if version, _, errSt = tx.version("station", extId); errSt != nil {
+				return errSt
+			}
+
+			name = map[uuid.UUID]string{station.Id: station.RuName}
+			return localize(tx, prefs, name)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.Header("ETag", versionTag(version, prefs))
+		ctx.JSON(struct {
+			Id       uuid.UUID `json:"id"`
+			Office   uuid.UUID `json:"office"`
+			District uuid.UUID `json:"district"`
+			RuName   string    `json:"ru_name"`
+			Name     string    `json:"name"`
+			location
+		}{
+			station.Id, station.Office, station.District, station.RuName, name[station.Id],
+			location{station.Lat, station.Lon, station.Address},
+		})
+	} else {
+		respondError(ctx, apiError{code: "not_found.station"})
+	}
+}
+
+// getDistrictStations lists the stations voting in a district.
+func getDistrictStations(ctx iris.Context) {
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
 	var found bool
-	var stations []station
+	var stations []stationRecord
+	var localized map[uuid.UUID]string
 
 	{
-		errTx := doTx(true, func(tx *sql.Tx) error {
-			rawOffices, errFA1 := fetchAll(tx, office{}, `SELECT int_id FROM office WHERE ext_id=$1`, extId)
-			if errFA1 != nil {
-				return errFA1
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			_, ok, errDs := tx.district(extId)
+			if errDs != nil {
+				return errDs
 			}
 
-			offices := rawOffices.([]office)
-			if found = len(offices) > 0; !found {
+			if found = ok; !found {
 				return nil
 			}
 
-			rawStations, errFA2 := fetchAll(
-				tx, station{},
-				"SELECT s.ext_id, d.ext_id, s.ru_name "+
-					"FROM station s INNER JOIN district d ON d.int_id=s.district WHERE s.office=$1",
-				offices[0].IntId,
-			)
-			if errFA2 != nil {
-				return errFA2
+			var errSs error
+			if stations, errSs = tx.districtStations(extId); errSs != nil {
+				return errSs
+			}
+
+			localized = make(map[uuid.UUID]string, len(stations))
+
+			for _, row := range stations {
+				localized[row.Id] = row.RuName
 			}
 
-			stations = rawStations.([]station)
-			return nil
+			return localize(tx, prefs, localized)
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
 	if found {
 		type station struct {
-			District uuid.UUID `json:"district"`
-			RuName   string    `json:"ru_name"`
+			Office uuid.UUID `json:"office"`
+			RuName string    `json:"ru_name"`
+			Name   string    `json:"name"`
+			location
 		}
 
 		res := make(map[uuid.UUID]station, len(stations))
 
 		for _, row := range stations {
-			res[row.ExtId] = station{row.District, row.RuName}
+			res[row.Id] = station{row.Office, row.RuName, localized[row.Id], location{row.Lat, row.Lon, row.Address}}
 		}
 
 		ctx.JSON(res)
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such office"})
+		respondError(ctx, apiError{code: "not_found.district"})
 	}
 }
 
 func postStations(ctx iris.Context) {
 	var payload struct {
-		RuName   string    `json:"ru_name"`
-		District uuid.UUID `json:"district"`
-	}
-
-	type row struct {
-		IntId int16
+		RuName   string            `json:"ru_name"`
+		District uuid.UUID         `json:"district"`
+		Names    map[string]string `json:"names"`
+		location
 	}
 
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
-	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errRJ.Error()})
+	body, errGB := ctx.GetBody()
+	if errGB != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errGB.Error()})
+		return
+	}
+
+	if errUm := json.Unmarshal(body, &payload); errUm != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errUm.Error()})
 		return
 	}
 
 	if strings.TrimSpace(payload.RuName) == "" {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".ru_name missing"})
+		respondError(ctx, apiError{code: "validation.ru_name_missing", field: "ru_name"})
+		return
+	}
+
+	names, invalid := validateNames(payload.Names)
+	if invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	given := newGivenLocation(body)
+	if invalid := given.validate(); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	if invalid := payload.validate(); invalid != nil {
+		respondError(ctx, *invalid)
 		return
 	}
 
 	if payload.District == uuid.Nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{".district missing"})
+		respondError(ctx, apiError{code: "validation.district_missing", field: "district"})
 		return
 	}
 
-	var foundDistrict, foundStation bool
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var foundStation, allowed, current, foundDistrict bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			rawDistricts, errFA := fetchAll(
-				tx, row{}, `SELECT int_id FROM district WHERE ext_id=$1`, payload.District,
-			)
-			if errFA != nil {
-				return errFA
+		errTx := doTx(false, func(tx storeTx) error {
+			var errLU error
+			if foundStation, allowed, errLU = mayEditStation(tx, p, extId); errLU != nil || !allowed {
+				return errLU
 			}
 
-			districts := rawDistricts.([]row)
-			if foundDistrict = len(districts) > 0; !foundDistrict {
-				return nil
+			if current, errLU = checkVersion(tx, "station", extId, versions); errLU != nil || !current {
+				return errLU
 			}
 
-			res, errEx := tx.Exec(
-				`UPDATE station SET ru_name=$1, district=$2 WHERE ext_id=$3`, payload.RuName, districts[0].IntId, extId,
-			)
-			if errEx != nil {
-				return errEx
+			if _, foundDistrict, errLU = tx.district(payload.District); errLU != nil || !foundDistrict {
+				return errLU
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			old, _, errOl := tx.station(extId)
+			if errOl != nil {
+				return errOl
 			}
 
-			foundStation = rows > 0
+			payload.keep(location{old.Lat, old.Lon, old.Address}, given)
+
+			return audited(tx, p, "station", extId, func() error {
+				_, errUS := tx.updateStation(stationRecord{
+					Id: extId, District: payload.District, RuName: payload.RuName,
+					Lat: payload.Lat, Lon: payload.Lon, Address: payload.Address,
+				})
+				if errUS != nil {
+					return errUS
+				}
 
-			return nil
+				return putNames(tx, extId, names)
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if foundDistrict {
-		if foundStation {
-			ctx.StatusCode(204)
-		} else {
-			ctx.StatusCode(404)
-			ctx.JSON(errorResponse{"no such station"})
-		}
+	if !foundStation {
+		respondError(ctx, apiError{code: "not_found.station"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
+	} else if !foundDistrict {
+		respondError(ctx, apiError{code: "not_found.district"})
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such district"})
+		ctx.StatusCode(204)
 	}
 }
 
 func deleteStations(ctx iris.Context) {
 	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
 	if errPU != nil {
-		ctx.StatusCode(400)
-		ctx.JSON(errorResponse{errPU.Error()})
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
 		return
 	}
 
-	var found bool
+	versions, ok := versionParam(ctx)
+	if !ok {
+		return
+	}
+
+	p := principalOf(ctx)
+	var found, allowed, current bool
 
 	{
-		errTx := doTx(false, func(tx *sql.Tx) error {
-			res, errEx := tx.Exec(`DELETE FROM station WHERE ext_id=$1`, extId)
-			if errEx != nil {
-				return errEx
+		errTx := doTx(false, func(tx storeTx) error {
+			var errME error
+			if found, allowed, errME = mayEditStation(tx, p, extId); errME != nil || !allowed {
+				return errME
 			}
 
-			rows, errRA := res.RowsAffected()
-			if errRA != nil {
-				return errRA
+			if current, errME = checkVersion(tx, "station", extId, versions); errME != nil || !current {
+				return errME
 			}
 
-			found = rows > 0
-
-			return nil
+			return audited(tx, p, "station", extId, func() error {
+				_, errDS := tx.deleteStation(extId)
+				return errDS
+			})
 		})
 		if errTx != nil {
-			ctx.StatusCode(500)
-			ctx.JSON(errorResponse{errTx.Error()})
+			respondInternal(ctx, errTx)
 			return
 		}
 	}
 
-	if found {
-		ctx.StatusCode(204)
+	if !found {
+		respondError(ctx, apiError{code: "not_found.station"})
+	} else if !allowed {
+		respondError(ctx, apiError{code: "forbidden.state"})
+	} else if !current {
+		respondError(ctx, apiError{code: "precondition_failed.version"})
 	} else {
-		ctx.StatusCode(404)
-		ctx.JSON(errorResponse{"no such station"})
+		ctx.StatusCode(204)
 	}
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestStationsRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district1 := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	district2 := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №2"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	stations := "/v1/offices/" + office + "/stations"
+
+	anon.GET(stations).Expect().Status(200).JSON().Object().Empty()
+
+	id := create(admin, stations, map[string]interface{}{
+		"ru_name": "УИК №8001", "district": district1, "lat": 52.5163, "lon": 13.3777,
+	})
+
+	station := anon.GET(stations).Expect().Status(200).JSON().Object().Value(id).Object()
+	station.ValueEqual("district", district1).ValueEqual("ru_name", "УИК №8001").ValueEqual("lat", 52.5163)
+
+	admin.POST("/v1/stations/" + id).WithJSON(map[string]interface{}{
+		"ru_name": "УИК №8002", "district": district2, "address": "Unter den Linden 63-65",
+	}).Expect().Status(204)
+
+	// The location is kept unless given.
+	station = anon.GET(stations).Expect().Status(200).JSON().Object().Value(id).Object()
+	station.ValueEqual("district", district2).ValueEqual("ru_name", "УИК №8002").ValueEqual("lat", 52.5163)
+	station.ValueEqual("address", "Unter den Linden 63-65")
+
+	// Null clears it.
+	admin.POST("/v1/stations/" + id).WithJSON(map[string]interface{}{
+		"ru_name": "УИК №8002", "district": district2, "lat": nil, "lon": nil, "address": nil,
+	}).Expect().Status(204)
+
+	anon.GET("/v1/stations/"+id).Expect().Status(200).JSON().Object().
+		ValueEqual("lat", nil).ValueEqual("lon", nil).ValueEqual("address", nil)
+
+	admin.DELETE("/v1/stations/" + id).Expect().Status(204)
+	admin.DELETE("/v1/stations/" + id).Expect().Status(404)
+
+	anon.GET(stations).Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestStationsNotFound(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	election := newTestElection(admin)
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	missing := uuid.New().String()
+
+	admin.PUT("/v1/offices/"+office+"/stations").WithJSON(map[string]string{"ru_name": "УИК №8002"}).
+		Expect().Status(400).JSON().Object().ValueEqual("code", "validation.district_missing")
+
+	admin.PUT("/v1/offices/"+missing+"/stations").WithJSON(map[string]string{
+		"ru_name": "УИК №8002", "district": district,
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.office")
+
+	admin.PUT("/v1/offices/"+office+"/stations").WithJSON(map[string]string{
+		"ru_name": "УИК №8002", "district": missing,
+	}).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.district")
+
+	admin.POST("/v1/stations/"+station).WithJSON(map[string]string{"ru_name": "УИК №8001", "district": missing}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.district")
+
+	admin.POST("/v1/stations/"+missing).WithJSON(map[string]string{"ru_name": "УИК №8001", "district": district}).
+		Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.station")
+
+	anon.GET("/v1/offices/" + missing + "/stations").Expect().Status(404)
+	anon.GET("/v1/elections/" + missing + "/offices/" + office + "/stations").Expect().Status(404)
+
+	anon.GET("/v1/elections/" + election + "/offices/" + office + "/stations").Expect().Status(200).JSON().Object().
+		Keys().ContainsOnly(station)
+}
+
+func TestOfficesWithStationsCantBeDeleted(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	admin.DELETE("/v1/offices/"+office).Expect().Status(409).JSON().Object().
+		ValueEqual("dependents", map[string][]string{"stations": {station}})
+
+	admin.DELETE("/v1/districts/"+district).Expect().Status(409).JSON().Object().
+		ValueEqual("dependents", map[string][]string{"stations": {station}})
+
+	admin.DELETE("/v1/offices/"+office).WithQuery("cascade", "true").Expect().Status(204)
+	admin.DELETE("/v1/stations/" + station).Expect().Status(404)
+	admin.DELETE("/v1/districts/" + district).Expect().Status(204)
+}
+
+func TestStationsSingle(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]interface{}{
+		"ru_name": "УИК №8001", "district": district, "names": map[string]string{"en": "Polling station 8001"},
+	})
+
+	anon.GET("/v1/stations/"+station).WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object().
+		ValueEqual("id", station).ValueEqual("office", office).ValueEqual("district", district).
+		ValueEqual("name", "Polling station 8001")
+
+	anon.GET("/v1/districts/"+district+"/stations").Expect().Status(200).JSON().Object().
+		Value(station).Object().ValueEqual("office", office).ValueEqual("ru_name", "УИК №8001")
+
+	admin.DELETE("/v1/stations/" + station).Expect().Status(204)
+
+	anon.GET("/v1/stations/"+station).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.station")
+	anon.GET("/v1/districts/" + district + "/stations").Expect().Status(200).JSON().Object().Empty()
+	anon.GET("/v1/districts/"+office+"/stations").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.district")
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// store is a storage backend, see initDb.
+type store interface {
+	// tx runs f in a serializable transaction, read-only if ro. The transaction is rolled back if f fails.
+	tx(ro bool, f func(tx storeTx) error) error
+}
+
+// storeTx is a transaction of a store. Lookups report whether the requested record exists,
+// updates and deletions whether they affected one.
+type storeTx interface {
+	states() ([]stateRecord, error)
+	state(id uuid.UUID) (stateRecord, bool, error)
+	stateByCode(code string) (stateRecord, bool, error)
+	putState(s stateRecord) error
+	// updateState keeps the ISO code unless s has one.
+	updateState(s stateRecord) (bool, error)
+	deleteState(id uuid.UUID) (bool, error)
+
+	// offices lists the offices of state, only those with a matching jurisdiction if any filter is given.
+	offices(state uuid.UUID, subdivision, city string) ([]officeRecord, error)
+	locatedOffices() ([]officeRecord, error)
+	office(id uuid.UUID) (officeRecord, bool, error)
+	putOffice(o officeRecord) error
+	// updateOffice keeps the state.
+	updateOffice(o officeRecord) (bool, error)
+	deleteOffice(id uuid.UUID) (bool, error)
+	jurisdiction(office uuid.UUID) (jurisdiction, error)
+	replaceJurisdiction(office uuid.UUID, j jurisdiction) error
+
+	// stations lists the stations of office which belong to a district of election.
+	stations(office, election uuid.UUID) ([]stationRecord, error)
+	// officeStations lists the stations of office in all elections.
+	officeStations(office uuid.UUID) ([]stationRecord, error)
+	districtStations(district uuid.UUID) ([]stationRecord, error)
+	station(id uuid.UUID) (stationRecord, bool, error)
+	putStation(s stationRecord) error
+	// updateStation keeps the office.
+	updateStation(s stationRecord) (bool, error)
+	deleteStation(id uuid.UUID) (bool, error)
+
+	districts(election uuid.UUID) ([]districtRecord, error)
+	district(id uuid.UUID) (districtRecord, bool, error)
+	putDistrict(d districtRecord) error
+	// updateDistrict keeps the election.
+	updateDistrict(d districtRecord) (bool, error)
+	deleteDistrict(id uuid.UUID) (bool, error)
+
+	candidates(district uuid.UUID) ([]candidateRecord, error)
+	candidate(id uuid.UUID) (candidateRecord, bool, error)
+	putCandidate(c candidateRecord) error
+	// updateCandidate keeps the district.
+	updateCandidate(c candidateRecord) (bool, error)
+	deleteCandidate(id uuid.UUID) (bool, error)
+
+	recommendation(district uuid.UUID) (recommendationRecord, bool, error)
+	// putRecommendation replaces the recommendation for the district of r, if any. New ones are drafts.
+	putRecommendation(r recommendationRecord) error
+	deleteRecommendation(district uuid.UUID) (bool, error)
+
+	elections() ([]electionRecord, error)
+	election(id uuid.UUID) (electionRecord, bool, error)
+	// latestElection returns the election voting most recently.
+	latestElection() (electionRecord, bool, error)
+	putElection(e electionRecord) error
+	updateElection(e electionRecord) (bool, error)
+	deleteElection(id uuid.UUID) (bool, error)
+
+	translations(entities []uuid.UUID, langs []string) ([]translationRecord, error)
+	replaceTranslations(entity uuid.UUID, names map[string]string) error
+	// names returns all translations of entity by language.
+	names(entity uuid.UUID) (map[string]string, error)
+
+	accounts() ([]accountRecord, error)
+	account(id uuid.UUID) (accountRecord, bool, error)
+	accountByName(name string) (accountRecord, bool, error)
+	putAccount(a accountRecord) error
+	// updateAccount keeps the password hash unless a has one.
+	updateAccount(a accountRecord) (bool, error)
+	deleteAccount(id uuid.UUID) (bool, error)
+	// grants lists the states whose offices and stations account may edit.
+	grants(account uuid.UUID) ([]uuid.UUID, error)
+	replaceGrants(account uuid.UUID, states []uuid.UUID) error
+
+	tokens() ([]tokenRecord, error)
+	token(id uuid.UUID) (tokenRecord, bool, error)
+	tokenByHash(hash []byte) (tokenRecord, bool, error)
+	putToken(t tokenRecord) error
+	touchToken(id uuid.UUID, used time.Time) error
+	deleteToken(id uuid.UUID) (bool, error)
+
+	// trash lists the deleted records which may still be restored, most recently deleted first.
+	trash() ([]trashedRecord, error)
+	// restore undeletes the record id of kind. It fails with errParentDeleted if a record it references is deleted
+	// and for states with errIsoCodeTaken if another one has the same ISO code meanwhile.
+	restore(kind string, id uuid.UUID) (bool, error)
+	// purge finally deletes the records deleted before before and returns how many.
+	purge(before time.Time) (int, error)
+
+	// drafts lists the records not published yet at the given time.
+	drafts(at time.Time) ([]draftRecord, error)
+	// publish publishes all drafts at the given time and returns them.
+	publish(at time.Time) ([]draftRecord, error)
+
+	// version returns the version of the record id of kind (or the recommendation for the district id),
+	// which every update bumps.
+	version(kind string, id uuid.UUID) (int64, bool, error)
+
+	// revision returns the current data revision. It has been modified when it was bumped, when a draft
+	// has been published or when a recommendation is to be published by at, whichever is latest.
+	revision(at time.Time) (revisionRecord, error)
+
+	// bumpRevision bumps the data revision unless it has already been bumped in the transaction.
+	// Transactions changing public data have to, see revisionRecord.
+	bumpRevision() error
+	// putChange logs a change of the record id of kind in the current revision, see changes.
+	putChange(kind string, id uuid.UUID) error
+	// changes lists the records changed after the revision since, each once with its latest change, oldest first.
+	changes(since int64) ([]changeRecord, error)
+
+	putAuditEvent(e auditRecord) error
+	// auditEvents lists the events oldest first, only those of entity (unless uuid.Nil) and not before since (if any).
+	auditEvents(entity uuid.UUID, since *time.Time) ([]auditRecord, error)
+}
+
+// revisionRecord is the version of all public data which every transaction changing any bumps.
+type revisionRecord struct {
+	Revision int64
+	Modified time.Time
+}
+
+type changeRecord struct {
+	Revision int64
+	Kind     string
+	Id       uuid.UUID
+}
+
+type stateRecord struct {
+	Id      uuid.UUID `json:"id"`
+	RuName  string    `json:"ru_name"`
+	IsoCode *string   `json:"iso_code"`
+}
+
+type officeRecord struct {
+	Id      uuid.UUID `json:"id"`
+	State   uuid.UUID `json:"state"`
+	RuName  string    `json:"ru_name"`
+	Lat     *float64  `json:"lat"`
+	Lon     *float64  `json:"lon"`
+	Address *string   `json:"address"`
+}
+
+type stationRecord struct {
+	Id       uuid.UUID `json:"id"`
+	Office   uuid.UUID `json:"office"`
+	District uuid.UUID `json:"district"`
+	RuName   string    `json:"ru_name"`
+	Lat      *float64  `json:"lat"`
+	Lon      *float64  `json:"lon"`
+	Address  *string   `json:"address"`
+}
+
+type districtRecord struct {
+	Id       uuid.UUID `json:"id"`
+	Election uuid.UUID `json:"election"`
+	RuName   string    `json:"ru_name"`
+}
+
+type candidateRecord struct {
+	Id       uuid.UUID `json:"id"`
+	District uuid.UUID `json:"district"`
+	RuName   string    `json:"ru_name"`
+	Party    string    `json:"party"`
+	Status   string    `json:"status"`
+}
+
+type recommendationRecord struct {
+	District  uuid.UUID `json:"district"`
+	Candidate uuid.UUID `json:"candidate"`
+	Note      string    `json:"note"`
+	Published time.Time `json:"published"`
+}
+
+type electionRecord struct {
+	Id         uuid.UUID `json:"id"`
+	RuName     string    `json:"ru_name"`
+	VotingFrom time.Time `json:"voting_from"`
+	VotingTo   time.Time `json:"voting_to"`
+	Status     string    `json:"status"`
+}
+
+type translationRecord struct {
+	Entity uuid.UUID
+	Lang   string
+	Name   string
+}
+
+type accountRecord struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// Hash is the bcrypt hash of the password.
+	Hash []byte `json:"-"`
+	Role string `json:"role"`
+}
+
+type tokenRecord struct {
+	Id uuid.UUID `json:"id"`
+	// Account is uuid.Nil for the bootstrap superuser's tokens.
+	Account uuid.UUID `json:"account"`
+	Name    string    `json:"name"`
+	// Hash is the SHA-256 hash of the token.
+	Hash     []byte     `json:"-"`
+	Scopes   []string   `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires"`
+	LastUsed *time.Time `json:"last_used"`
+}
+
+type auditRecord struct {
+	Id   uuid.UUID
+	Time time.Time
+	// Actor is uuid.Nil for the bootstrap superuser.
+	Actor      uuid.UUID
+	ActorName  string
+	Action     string
+	EntityType string
+	Entity     uuid.UUID
+	// Before and After are JSON snapshots of the entity, nil if it didn't exist.
+	Before []byte
+	After  []byte
+}
+
+// draftRecord identifies an unpublished record of a kind, i.e. the type of an audited entity.
+// Recommendations are identified by their district.
+type draftRecord struct {
+	Kind string
+	Id   uuid.UUID
+}
+
+// trashedRecord is a deleted record of a kind, i.e. the type of an audited entity.
+type trashedRecord struct {
+	Kind      string
+	Id        uuid.UUID
+	RuName    string
+	DeletedAt time.Time
+}
+
+// referenceError is what the stores report on deletions of records live ones still reference.
+type referenceError struct {
+	table, referencedBy string
+}
+
+func (re referenceError) Error() string {
+	return fmt.Sprintf("%s is still referenced from %s", re.table, re.referencedBy)
+}
+
+var errParentDeleted = errors.New("referenced record deleted")
+var errIsoCodeTaken = errors.New("ISO code taken")
+
+var backend store
+
+func doTx(ro bool, f func(tx storeTx) error) error {
+	return backend.tx(ro, f)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"strings"
+	"time"
+)
+
+// tokenScopes are all the scopes tokens may have, see mustHaveRole.
+var tokenScopes = map[string]struct{}{
+	"states:write":          {},
+	"offices:write":         {},
+	"stations:write":        {},
+	"districts:write":       {},
+	"candidates:write":      {},
+	"recommendations:write": {},
+	"elections:write":       {},
+	"accounts:read":         {},
+	"accounts:write":        {},
+	"audit:read":            {},
+	"trash:read":            {},
+	"trash:write":           {},
+	"drafts:read":           {},
+	"publish:write":         {},
+}
+
+// tokenPrefix makes tokens recognizable, e.g. by secret scanners.
+const tokenPrefix = "vt_"
+
+type tokenPayload struct {
+	Name    string     `json:"name"`
+	Scopes  []string   `json:"scopes"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (tp *tokenPayload) validate(now time.Time) *apiError {
+	if tp.Name = strings.TrimSpace(tp.Name); tp.Name == "" {
+		return &apiError{code: "validation.token_name_missing", field: "name"}
+	}
+
+	if len(tp.Scopes) < 1 {
+		return &apiError{code: "validation.scopes_missing", field: "scopes"}
+	}
+
+	for _, scope := range tp.Scopes {
+		if _, ok := tokenScopes[scope]; !ok {
+			return &apiError{"validation.scope_invalid", "scopes", scope}
+		}
+	}
+
+	if tp.Expires != nil && !tp.Expires.After(now) {
+		return &apiError{code: "validation.expires_invalid", field: "expires"}
+	}
+
+	return nil
+}
+
+// newToken generates a random token and returns it together with its hash.
+func newToken() (string, []byte, error) {
+	var secret [32]byte
+	if _, errRd := rand.Read(secret[:]); errRd != nil {
+		return "", nil, errRd
+	}
+
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret[:])
+	hash := sha256.Sum256([]byte(token))
+
+	return token, hash[:], nil
+}
+
+// putTokens mints a token for the requesting account. The token itself is only shown once.
+func putTokens(ctx iris.Context) {
+	var payload tokenPayload
+
+	if errRJ := ctx.ReadJSON(&payload); errRJ != nil {
+		respondError(ctx, apiError{"validation.malformed_json", "", errRJ.Error()})
+		return
+	}
+
+	now := time.Now()
+	if invalid := payload.validate(now); invalid != nil {
+		respondError(ctx, *invalid)
+		return
+	}
+
+	token, hash, errNT := newToken()
+	if errNT != nil {
+		respondInternal(ctx, errNT)
+		return
+	}
+
+	uid, errNR := uuid.NewRandom()
+	if errNR != nil {
+		respondInternal(ctx, errNR)
+		return
+	}
+
+	p := principalOf(ctx)
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			return audited(tx, p, "token", uid, func() error {
+				return tx.putToken(tokenRecord{
+					Id: uid, Account: p.Account, Name: payload.Name, Hash: hash,
+					Scopes: payload.Scopes, Created: now, Expires: payload.Expires,
+				})
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	ctx.StatusCode(201)
+	ctx.JSON(struct {
+		Id    uuid.UUID `json:"id"`
+		Token string    `json:"token"`
+	}{uid, token})
+}
+
+// getTokens lists the requesting account's tokens, or all of them for admins.
+func getTokens(ctx iris.Context) {
+	p := principalOf(ctx)
+	var tokens []tokenRecord
+
+	{
+		errTx := doTx(true, func(tx storeTx) error {
+			var errTk error
+			tokens, errTk = tx.tokens()
+			return errTk
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	type token struct {
+		Account  uuid.UUID  `json:"account"`
+		Name     string     `json:"name"`
+		Scopes   []string   `json:"scopes"`
+		Created  time.Time  `json:"created"`
+		Expires  *time.Time `json:"expires"`
+		LastUsed *time.Time `json:"last_used"`
+	}
+
+	res := map[uuid.UUID]token{}
+
+	for _, row := range tokens {
+		if p.Role == "admin" || row.Account == p.Account {
+			res[row.Id] = token{row.Account, row.Name, row.Scopes, row.Created, row.Expires, row.LastUsed}
+		}
+	}
+
+	ctx.JSON(res)
+}
+
+// deleteTokens revokes a token of the requesting account, or any one for admins.
+// Tokens can't be updated, so they have no versions and If-Match doesn't apply.
+func deleteTokens(ctx iris.Context) {
+	extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+	if errPU != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+		return
+	}
+
+	p := principalOf(ctx)
+	var found bool
+
+	{
+		errTx := doTx(false, func(tx storeTx) error {
+			token, ok, errTk := tx.token(extId)
+			if errTk != nil {
+				return errTk
+			}
+
+			// Others' tokens don't exist as far as non-admins are concerned.
+			if found = ok && (p.Role == "admin" || token.Account == p.Account); !found {
+				return nil
+			}
+
+			return audited(tx, p, "token", extId, func() error {
+				_, errDT := tx.deleteToken(extId)
+				return errDT
+			})
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if found {
+		ctx.StatusCode(204)
+	} else {
+		respondError(ctx, apiError{code: "not_found.token"})
+	}
+}
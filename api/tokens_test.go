@@ -0,0 +1,123 @@
+package main
+
+import (
+	"github.com/gavv/httpexpect"
+	"testing"
+	"time"
+)
+
+// newTestToken mints a token via client and returns its ID and a client authenticated with it.
+func newTestToken(anon, client *httpexpect.Expect, scopes ...string) (id string, bearer *httpexpect.Expect) {
+	minted := client.PUT("/v1/tokens").WithJSON(map[string]interface{}{"name": "cik2api", "scopes": scopes}).
+		Expect().Status(201).JSON().Object()
+
+	id = minted.Value("id").String().Raw()
+	token := minted.Value("token").String().Raw()
+	bearer = anon.Builder(func(req *httpexpect.Request) {
+		req.WithHeader("Authorization", "Bearer "+token)
+	})
+
+	return
+}
+
+func TestTokensScopes(t *testing.T) {
+	anon, admin := newTestApi(t)
+	_, bearer := newTestToken(anon, admin, "states:write")
+
+	create(bearer, "/v1/states", map[string]string{"ru_name": "Германия"})
+
+	bearer.PUT("/v1/districts").WithJSON(map[string]string{"ru_name": "Округ"}).
+		Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.scope")
+
+	bearer.GET("/v1/accounts").Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.scope")
+
+	// Tokens can't mint or list tokens, only passwords can.
+	bearer.PUT("/v1/tokens").WithJSON(map[string]interface{}{"name": "x", "scopes": []string{"states:write"}}).
+		Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.scope")
+
+	bearer.GET("/v1/tokens").Expect().Status(403)
+
+	anon.PUT("/v1/states").WithHeader("Authorization", "Bearer vt_nonsense").
+		WithJSON(map[string]string{"ru_name": "Австрия"}).Expect().Status(401).JSON().Object().
+		ValueEqual("code", "unauthorized")
+}
+
+func TestTokensFollowTheirAccount(t *testing.T) {
+	anon, admin := newTestApi(t)
+	accountId, editor := newTestAccount(anon, admin, "editor", "editor")
+	_, bearer := newTestToken(anon, editor, "states:write", "accounts:read")
+
+	create(bearer, "/v1/states", map[string]string{"ru_name": "Германия"})
+
+	// Scopes don't lift the role's limits.
+	bearer.GET("/v1/accounts").Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.role")
+
+	admin.POST("/v1/accounts/" + accountId).WithJSON(map[string]string{"name": "editor", "role": "viewer"}).
+		Expect().Status(204)
+
+	bearer.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Австрия"}).
+		Expect().Status(403).JSON().Object().ValueEqual("code", "forbidden.role")
+
+	admin.DELETE("/v1/accounts/" + accountId).Expect().Status(204)
+
+	bearer.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Австрия"}).Expect().Status(401)
+	admin.GET("/v1/tokens").Expect().Status(200).JSON().Object().Empty()
+}
+
+func TestTokensRevocation(t *testing.T) {
+	anon, admin := newTestApi(t)
+	_, editor := newTestAccount(anon, admin, "editor", "editor")
+	_, viewer := newTestAccount(anon, admin, "viewer", "viewer")
+	id, bearer := newTestToken(anon, editor, "states:write")
+
+	create(bearer, "/v1/states", map[string]string{"ru_name": "Германия"})
+
+	listed := editor.GET("/v1/tokens").Expect().Status(200).JSON().Object()
+	listed.Keys().Length().Equal(1)
+
+	token := listed.Value(id).Object()
+	token.ValueEqual("name", "cik2api").ValueEqual("scopes", []string{"states:write"}).ValueEqual("expires", nil)
+	token.Value("last_used").String().NotEmpty()
+	token.NotContainsKey("token").NotContainsKey("hash")
+
+	viewer.GET("/v1/tokens").Expect().Status(200).JSON().Object().Empty()
+	admin.GET("/v1/tokens").Expect().Status(200).JSON().Object().Keys().Length().Equal(1)
+
+	viewer.DELETE("/v1/tokens/"+id).Expect().Status(404).JSON().Object().ValueEqual("code", "not_found.token")
+	editor.DELETE("/v1/tokens/" + id).Expect().Status(204)
+	editor.DELETE("/v1/tokens/" + id).Expect().Status(404)
+
+	bearer.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Австрия"}).Expect().Status(401)
+}
+
+func TestTokensExpiry(t *testing.T) {
+	anon, admin := newTestApi(t)
+	_, bearer := newTestToken(anon, admin, "states:write")
+
+	for id := range backend.(*memStore).data.tokens {
+		token := backend.(*memStore).data.tokens[id]
+		past := time.Now().Add(-time.Second)
+		token.Expires = &past
+		backend.(*memStore).data.tokens[id] = token
+	}
+
+	bearer.PUT("/v1/states").WithJSON(map[string]string{"ru_name": "Германия"}).Expect().Status(401)
+}
+
+func TestTokensValidation(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	for payload, code := range map[string]string{
+		`{"name": " ", "scopes": ["states:write"]}`: "validation.token_name_missing",
+		`{"name": "x"}`: "validation.scopes_missing",
+		`{"name": "x", "scopes": ["states:read"]}`:                                     "validation.scope_invalid",
+		`{"name": "x", "scopes": ["states:write"], "expires": "2000-01-01T00:00:00Z"}`: "validation.expires_invalid",
+	} {
+		admin.PUT("/v1/tokens").WithText(payload).WithHeader("Content-Type", "application/json").
+			Expect().Status(400).JSON().Object().ValueEqual("code", code)
+	}
+
+	admin.PUT("/v1/tokens").WithJSON(map[string]interface{}{
+		"name": "x", "scopes": []string{"states:write"}, "expires": time.Now().Add(time.Hour),
+	}).Expect().Status(201)
+}
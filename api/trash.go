@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+// trashKinds are the entity types whose deleted records go to the trash first.
+var trashKinds = []string{"state", "office", "station", "district", "candidate", "election"}
+
+// trashRetention is how long deleted records may be restored before purgeTrash deletes them for good.
+var trashRetention = 30 * 24 * time.Hour
+
+// trashPurgeInterval is how often purgeTrash runs.
+var trashPurgeInterval = time.Hour
+
+func initTrash() {
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{{"VOTEAPI_TRASH_RETENTION", &trashRetention}, {"VOTEAPI_TRASH_PURGE_INTERVAL", &trashPurgeInterval}} {
+		raw, ok := os.LookupEnv(setting.name)
+		if !ok {
+			continue
+		}
+
+		d, errPD := time.ParseDuration(raw)
+		if errPD != nil || d < 0 {
+			log.WithFields(log.Fields{"var": setting.name, "value": raw}).Fatal("Bad duration")
+		}
+
+		*setting.value = d
+	}
+}
+
+// purgeTrash periodically deletes what's in the trash for longer than trashRetention. A zero interval disables it.
+func purgeTrash() {
+	if trashPurgeInterval == 0 {
+		return
+	}
+
+	for {
+		var purged int
+		before := time.Now().Add(-trashRetention)
+
+		errTx := doTx(false, func(tx storeTx) error {
+			var errPg error
+			purged, errPg = tx.purge(before)
+			return errPg
+		})
+		if errTx != nil {
+			log.WithFields(log.Fields{"error": errTx.Error()}).Error("Couldn't purge trash")
+		} else if purged > 0 {
+			log.WithFields(log.Fields{"purged": purged, "before": before}).Info("Purged trash")
+		}
+
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
+func getTrash(ctx iris.Context) {
+	var trash []trashedRecord
+
+	{
+		errTx := doTx(true, func(tx storeTx) error {
+			var errTr error
+			trash, errTr = tx.trash()
+			return errTr
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	type trashed struct {
+		// Kind is plural like in the URLs, e.g. offices.
+		Kind      string    `json:"kind"`
+		Id        uuid.UUID `json:"id"`
+		RuName    string    `json:"ru_name"`
+		DeletedAt time.Time `json:"deleted_at"`
+	}
+
+	res := make([]trashed, 0, len(trash))
+
+	for _, row := range trash {
+		res = append(res, trashed{row.Kind + "s", row.Id, row.RuName, row.DeletedAt})
+	}
+
+	ctx.JSON(res)
+}
+
+// restoreTrashed returns a handler restoring deleted records of kind from the trash.
+func restoreTrashed(kind string) iris.Handler {
+	return func(ctx iris.Context) {
+		extId, errPU := uuid.Parse(ctx.Params().Get("ext_id"))
+		if errPU != nil {
+			respondError(ctx, apiError{"validation.malformed_id", "", errPU.Error()})
+			return
+		}
+
+		p := principalOf(ctx)
+		var found bool
+
+		{
+			errTx := doTx(false, func(tx storeTx) error {
+				return auditedAs(tx, p, "restore", kind, extId, func() error {
+					var errRs error
+					found, errRs = tx.restore(kind, extId)
+					return errRs
+				})
+			})
+			switch errTx {
+			case nil:
+			case errParentDeleted:
+				respondError(ctx, apiError{code: "conflict.parent_deleted"})
+				return
+			case errIsoCodeTaken:
+				respondError(ctx, apiError{code: "conflict.iso_code_taken", field: "iso_code"})
+				return
+			default:
+				respondInternal(ctx, errTx)
+				return
+			}
+		}
+
+		if found {
+			ctx.StatusCode(204)
+		} else {
+			respondError(ctx, apiError{code: "not_found." + kind})
+		}
+	}
+}
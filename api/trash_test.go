@@ -0,0 +1,116 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestTrashRestoresWithNames(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	offices := "/v1/states/" + state + "/offices"
+	office := create(admin, offices, map[string]interface{}{
+		"ru_name": "Генеральное консульство в Мюнхене", "names": map[string]string{"de": "Generalkonsulat in München"},
+	})
+
+	admin.DELETE("/v1/offices/" + office).Expect().Status(204)
+
+	anon.GET(offices).Expect().Status(200).JSON().Object().Empty()
+	admin.POST("/v1/offices/" + office).WithJSON(map[string]string{"ru_name": "Консульство"}).Expect().Status(404)
+
+	trash := admin.GET("/v1/trash").Expect().Status(200).JSON().Array()
+	trash.Length().Equal(1)
+	trash.Element(0).Object().ValueEqual("kind", "offices").ValueEqual("id", office).
+		ValueEqual("ru_name", "Генеральное консульство в Мюнхене").ContainsKey("deleted_at")
+
+	admin.POST("/v1/offices/" + office + "/restore").Expect().Status(204)
+	admin.POST("/v1/offices/"+office+"/restore").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.office")
+
+	anon.GET(offices).WithHeader("Accept-Language", "de").Expect().Status(200).JSON().Object().
+		Equal(map[string]string{office: "Generalkonsulat in München"})
+
+	admin.GET("/v1/trash").Expect().Status(200).JSON().Array().Empty()
+
+	events := admin.GET("/v1/audit").WithQuery("entity", office).Expect().Status(200).JSON().Array()
+	events.Length().Equal(3)
+	events.Element(2).Object().ValueEqual("action", "restore").ValueEqual("before", nil)
+
+	// Kinds don't mix.
+	admin.POST("/v1/states/" + office + "/restore").Expect().Status(404)
+}
+
+func TestTrashRestoresParentsFirst(t *testing.T) {
+	_, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	district := create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №1"})
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	office := create(admin, "/v1/states/"+state+"/offices", map[string]string{"ru_name": "Посольство в Берлине"})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": district,
+	})
+
+	admin.DELETE("/v1/states/"+state).WithQuery("cascade", true).Expect().Status(204)
+	admin.GET("/v1/trash").Expect().Status(200).JSON().Array().Length().Equal(3)
+
+	admin.POST("/v1/stations/"+station+"/restore").Expect().Status(409).JSON().Object().
+		ValueEqual("code", "conflict.parent_deleted")
+
+	admin.POST("/v1/offices/" + office + "/restore").Expect().Status(409)
+	admin.POST("/v1/states/" + state + "/restore").Expect().Status(204)
+	admin.POST("/v1/offices/" + office + "/restore").Expect().Status(204)
+	admin.POST("/v1/stations/" + station + "/restore").Expect().Status(204)
+
+	admin.GET("/v1/offices/" + office + "/stations").Expect().Status(200).JSON().Object().Keys().
+		Elements(station)
+}
+
+func TestTrashIsoCodes(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	old := create(admin, "/v1/states", map[string]string{"ru_name": "ФРГ", "iso_code": "DE"})
+	admin.DELETE("/v1/states/" + old).Expect().Status(204)
+
+	anon.GET("/v1/states/by-code/DE").Expect().Status(404)
+
+	current := create(admin, "/v1/states", map[string]string{"ru_name": "Германия", "iso_code": "DE"})
+
+	admin.POST("/v1/states/"+old+"/restore").Expect().Status(409).JSON().Object().
+		ValueEqual("code", "conflict.iso_code_taken")
+
+	anon.GET("/v1/states/by-code/DE").Expect().Status(200).JSON().Object().ValueEqual("id", current)
+}
+
+func TestTrashPurge(t *testing.T) {
+	anon, admin := newTestApi(t)
+	_, editor := newTestAccount(anon, admin, "editor", "editor")
+
+	state := create(admin, "/v1/states", map[string]string{"ru_name": "Германия"})
+	admin.DELETE("/v1/states/" + state).Expect().Status(204)
+
+	editor.GET("/v1/trash").Expect().Status(403)
+	editor.POST("/v1/states/" + state + "/restore").Expect().Status(403)
+
+	var purged int
+
+	errTx := doTx(false, func(tx storeTx) error {
+		var errPg error
+		purged, errPg = tx.purge(time.Now().Add(time.Second))
+		return errPg
+	})
+	if errTx != nil {
+		t.Fatal(errTx)
+	}
+
+	if purged != 1 {
+		t.Errorf("purged %d records, not 1", purged)
+	}
+
+	admin.GET("/v1/trash").Expect().Status(200).JSON().Array().Empty()
+	admin.POST("/v1/states/" + state + "/restore").Expect().Status(404)
+	admin.POST("/v1/states/" + uuid.New().String() + "/restore").Expect().Status(404)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+)
+
+type treeDistrict struct {
+	RuName string `json:"ru_name"`
+	Name   string `json:"name"`
+}
+
+type treeStation struct {
+	RuName   string    `json:"ru_name"`
+	Name     string    `json:"name"`
+	District uuid.UUID `json:"district"`
+	location
+}
+
+type treeOffice struct {
+	RuName string `json:"ru_name"`
+	Name   string `json:"name"`
+	location
+	Stations map[uuid.UUID]treeStation `json:"stations"`
+}
+
+type treeState struct {
+	RuName  string                   `json:"ru_name"`
+	Name    string                   `json:"name"`
+	IsoCode *string                  `json:"iso_code"`
+	Offices map[uuid.UUID]treeOffice `json:"offices"`
+}
+
+// getTree serves all states with their offices and those with their stations of an election at once,
+// only one state if ?state is given. The districts are only those the stations refer to in the latter case.
+func getTree(ctx iris.Context) {
+	election, errEP := electionParam(ctx)
+	if errEP != nil {
+		respondError(ctx, apiError{"validation.malformed_id", "election", errEP.Error()})
+		return
+	}
+
+	var state uuid.UUID
+	if raw := ctx.URLParam("state"); raw != "" {
+		var errPU error
+		if state, errPU = uuid.Parse(raw); errPU != nil {
+			respondError(ctx, apiError{"validation.malformed_id", "state", errPU.Error()})
+			return
+		}
+	}
+
+	drafts, ok := draftsParam(ctx)
+	if !ok {
+		return
+	}
+
+	prefs := langPrefs(ctx)
+	var foundElection, foundState bool
+	var states []stateRecord
+	var offices map[uuid.UUID][]officeRecord
+	var stations map[uuid.UUID][]stationRecord
+	var districts []districtRecord
+	var localized map[uuid.UUID]string
+
+	{
+		errTx := doPublicTx(drafts, func(tx storeTx) error {
+			electionId, ok, errFE := findElection(tx, election)
+			if errFE != nil {
+				return errFE
+			}
+
+			if foundElection = ok; !foundElection {
+				return nil
+			}
+
+			if state == uuid.Nil {
+				var errSt error
+				if states, errSt = tx.states(); errSt != nil {
+					return errSt
+				}
+			} else {
+				row, ok, errSt := tx.state(state)
+				if errSt != nil {
+					return errSt
+				}
+
+				if !ok {
+					return nil
+				}
+
+				states = []stateRecord{row}
+			}
+
+			foundState = true
+			localized = map[uuid.UUID]string{}
+			offices = make(map[uuid.UUID][]officeRecord, len(states))
+			stations = map[uuid.UUID][]stationRecord{}
+			referenced := map[uuid.UUID]struct{}{}
+
+			for _, st := range states {
+				localized[st.Id] = st.RuName
+
+				stateOffices, errOs := tx.offices(st.Id, "", "")
+				if errOs != nil {
+					return errOs
+				}
+
+				offices[st.Id] = stateOffices
+
+				for _, office := range stateOffices {
+					localized[office.Id] = office.RuName
+
+					officeStations, errSs := tx.stations(office.Id, electionId)
+					if errSs != nil {
+						return errSs
+					}
+
+					stations[office.Id] = officeStations
+
+					for _, station := range officeStations {
+						localized[station.Id] = station.RuName
+						referenced[station.District] = struct{}{}
+					}
+				}
+			}
+
+			rows, errDs := tx.districts(electionId)
+			if errDs != nil {
+				return errDs
+			}
+
+			for _, district := range rows {
+				if _, ok := referenced[district.Id]; ok || state == uuid.Nil {
+					districts = append(districts, district)
+					localized[district.Id] = district.RuName
+				}
+			}
+
+			return localize(tx, prefs, localized)
+		})
+		if errTx != nil {
+			respondInternal(ctx, errTx)
+			return
+		}
+	}
+
+	if !foundElection {
+		respondError(ctx, apiError{code: "not_found.election"})
+	} else if !foundState {
+		respondError(ctx, apiError{code: "not_found.state"})
+	} else {
+		ctx.JSON(newTree(states, offices, stations, districts, localized))
+	}
+}
+
+// tree is what getTree serves.
+type tree struct {
+	States    map[uuid.UUID]treeState    `json:"states"`
+	Districts map[uuid.UUID]treeDistrict `json:"districts"`
+}
+
+func newTree(
+	states []stateRecord, offices map[uuid.UUID][]officeRecord, stations map[uuid.UUID][]stationRecord,
+	districts []districtRecord, localized map[uuid.UUID]string,
+) tree {
+
+	res := tree{make(map[uuid.UUID]treeState, len(states)), make(map[uuid.UUID]treeDistrict, len(districts))}
+
+	for _, st := range states {
+		ts := treeState{st.RuName, localized[st.Id], st.IsoCode, make(map[uuid.UUID]treeOffice, len(offices[st.Id]))}
+
+		for _, office := range offices[st.Id] {
+			to := treeOffice{
+				office.RuName, localized[office.Id], location{office.Lat, office.Lon, office.Address},
+				make(map[uuid.UUID]treeStation, len(stations[office.Id])),
+			}
+
+			for _, station := range stations[office.Id] {
+				to.Stations[station.Id] = treeStation{
+					station.RuName, localized[station.Id], station.District,
+					location{station.Lat, station.Lon, station.Address},
+				}
+			}
+
+			ts.Offices[office.Id] = to
+		}
+
+		res.States[st.Id] = ts
+	}
+
+	for _, district := range districts {
+		res.Districts[district.Id] = treeDistrict{district.RuName, localized[district.Id]}
+	}
+
+	return res
+}
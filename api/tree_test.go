@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTreeRoundTrip(t *testing.T) {
+	anon, admin := newTestApi(t)
+
+	newTestElection(admin)
+
+	districts := [2]string{
+		create(admin, "/v1/districts", map[string]interface{}{
+			"ru_name": "Округ №1", "names": map[string]string{"en": "District 1"},
+		}),
+		create(admin, "/v1/districts", map[string]string{"ru_name": "Округ №2"}),
+	}
+
+	germany := create(admin, "/v1/states", map[string]string{"ru_name": "Германия", "iso_code": "DE"})
+	france := create(admin, "/v1/states", map[string]string{"ru_name": "Франция"})
+	office := create(admin, "/v1/states/"+germany+"/offices", map[string]interface{}{
+		"ru_name": "Посольство в Берлине", "lat": 52.5, "lon": 13.4,
+	})
+	station := create(admin, "/v1/offices/"+office+"/stations", map[string]string{
+		"ru_name": "УИК №8001", "district": districts[0],
+	})
+
+	tree := anon.GET("/v1/tree").WithHeader("Accept-Language", "en").Expect().Status(200).JSON().Object()
+
+	tree.Value("states").Object().Keys().ContainsOnly(germany, france)
+	tree.Value("states").Object().Value(france).Object().Value("offices").Object().Empty()
+
+	de := tree.Value("states").Object().Value(germany).Object()
+	de.ValueEqual("ru_name", "Германия").ValueEqual("iso_code", "DE")
+
+	berlin := de.Value("offices").Object().Value(office).Object()
+	berlin.ValueEqual("ru_name", "Посольство в Берлине").ValueEqual("lat", 52.5)
+	berlin.Value("stations").Object().Value(station).Object().
+		ValueEqual("ru_name", "УИК №8001").ValueEqual("district", districts[0])
+
+	tree.Value("districts").Object().Keys().ContainsOnly(districts[0], districts[1])
+	tree.Value("districts").Object().Value(districts[0]).Object().ValueEqual("name", "District 1")
+
+	narrowed := anon.GET("/v1/tree").WithQuery("state", germany).Expect().Status(200).JSON().Object()
+	narrowed.Value("states").Object().Keys().ContainsOnly(germany)
+	narrowed.Value("districts").Object().Keys().ContainsOnly(districts[0])
+
+	anon.GET("/v1/tree").WithQuery("state", office).Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.state")
+	anon.GET("/v1/tree").WithQuery("state", "Германия").Expect().Status(400).JSON().Object().
+		ValueEqual("code", "validation.malformed_id").ValueEqual("field", "state")
+	anon.GET("/v1/elections/"+office+"/tree").Expect().Status(404).JSON().Object().
+		ValueEqual("code", "not_found.election")
+}
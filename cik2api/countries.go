@@ -0,0 +1,231 @@
+package main
+
+// countryCodes maps the normalized Russian country names used by the CIK to ISO 3166-1 alpha-2 codes.
+var countryCodes = map[string]string{
+	"австралия":   "AU",
+	"австрия":     "AT",
+	"азербайджан": "AZ",
+	"азербайджанская республика": "AZ",
+	"албания": "AL",
+	"алжир":   "DZ",
+	"алжирская народная демократическая республика": "DZ",
+	"ангола":    "AO",
+	"аргентина": "AR",
+	"аргентинская республика": "AR",
+	"армения":              "AM",
+	"республика армения":   "AM",
+	"афганистан":           "AF",
+	"бангладеш":            "BD",
+	"бахрейн":              "BH",
+	"беларусь":             "BY",
+	"республика беларусь":  "BY",
+	"белоруссия":           "BY",
+	"бельгия":              "BE",
+	"королевство бельгия":  "BE",
+	"бенин":                "BJ",
+	"болгария":             "BG",
+	"республика болгария":  "BG",
+	"боливия":              "BO",
+	"босния и герцеговина": "BA",
+	"ботсвана":             "BW",
+	"бразилия":             "BR",
+	"федеративная республика бразилия": "BR",
+	"бруней":                  "BN",
+	"бурунди":                 "BI",
+	"великобритания":          "GB",
+	"соединенное королевство": "GB",
+	"соединенное королевство великобритании и северной ирландии": "GB",
+	"венгрия":   "HU",
+	"венесуэла": "VE",
+	"вьетнам":   "VN",
+	"социалистическая республика вьетнам": "VN",
+	"габон":        "GA",
+	"гайана":       "GY",
+	"гана":         "GH",
+	"гватемала":    "GT",
+	"гвинея":       "GN",
+	"гвинея-бисау": "GW",
+	"германия":     "DE",
+	"федеративная республика германия": "DE",
+	"фрг":    "DE",
+	"греция": "GR",
+	"греческая республика": "GR",
+	"грузия":            "GE",
+	"дания":             "DK",
+	"королевство дания": "DK",
+	"джибути":           "DJ",
+	"египет":            "EG",
+	"арабская республика египет": "EG",
+	"замбия":               "ZM",
+	"зимбабве":             "ZW",
+	"израиль":              "IL",
+	"государство израиль":  "IL",
+	"индия":                "IN",
+	"республика индия":     "IN",
+	"индонезия":            "ID",
+	"республика индонезия": "ID",
+	"иордания":             "JO",
+	"ирак":                 "IQ",
+	"иран":                 "IR",
+	"исламская республика иран": "IR",
+	"ирландия":               "IE",
+	"исландия":               "IS",
+	"испания":                "ES",
+	"королевство испания":    "ES",
+	"италия":                 "IT",
+	"итальянская республика": "IT",
+	"йемен":                  "YE",
+	"кабо-верде":             "CV",
+	"казахстан":              "KZ",
+	"республика казахстан":   "KZ",
+	"камбоджа":               "KH",
+	"камерун":                "CM",
+	"канада":                 "CA",
+	"катар":                  "QA",
+	"кения":                  "KE",
+	"кипр":                   "CY",
+	"республика кипр":        "CY",
+	"киргизия":               "KG",
+	"кыргызстан":             "KG",
+	"кыргызская республика":  "KG",
+	"китай":                  "CN",
+	"китайская народная республика": "CN",
+	"кнр":              "CN",
+	"колумбия":         "CO",
+	"конго":            "CG",
+	"республика конго": "CG",
+	"демократическая республика конго": "CD",
+	"кндр": "KP",
+	"корейская народно-демократическая республика": "KP",
+	"республика корея":      "KR",
+	"южная корея":           "KR",
+	"коста-рика":            "CR",
+	"кот-д'ивуар":           "CI",
+	"куба":                  "CU",
+	"республика куба":       "CU",
+	"кувейт":                "KW",
+	"лаос":                  "LA",
+	"латвия":                "LV",
+	"латвийская республика": "LV",
+	"ливан":                 "LB",
+	"ливия":                 "LY",
+	"литва":                 "LT",
+	"литовская республика":  "LT",
+	"лихтенштейн":           "LI",
+	"люксембург":            "LU",
+	"маврикий":              "MU",
+	"мавритания":            "MR",
+	"мадагаскар":            "MG",
+	"малайзия":              "MY",
+	"мали":                  "ML",
+	"мальдивы":              "MV",
+	"мальта":                "MT",
+	"марокко":               "MA",
+	"королевство марокко":   "MA",
+	"мексика":               "MX",
+	"мексиканские соединенные штаты": "MX",
+	"мозамбик":           "MZ",
+	"молдавия":           "MD",
+	"молдова":            "MD",
+	"республика молдова": "MD",
+	"монако":             "MC",
+	"монголия":           "MN",
+	"мьянма":             "MM",
+	"намибия":            "NA",
+	"непал":              "NP",
+	"нигер":              "NE",
+	"нигерия":            "NG",
+	"нидерланды":         "NL",
+	"королевство нидерландов":       "NL",
+	"никарагуа":                     "NI",
+	"новая зеландия":                "NZ",
+	"норвегия":                      "NO",
+	"королевство норвегия":          "NO",
+	"объединенные арабские эмираты": "AE",
+	"оаэ":               "AE",
+	"оман":              "OM",
+	"пакистан":          "PK",
+	"палестина":         "PS",
+	"панама":            "PA",
+	"парагвай":          "PY",
+	"перу":              "PE",
+	"польша":            "PL",
+	"республика польша": "PL",
+	"португалия":        "PT",
+	"португальская республика": "PT",
+	"руанда":            "RW",
+	"румыния":           "RO",
+	"саудовская аравия": "SA",
+	"королевство саудовская аравия": "SA",
+	"северная македония":            "MK",
+	"республика северная македония": "MK",
+	"сейшельские острова":           "SC",
+	"сейшелы":                       "SC",
+	"сенегал":                       "SN",
+	"сербия":                        "RS",
+	"республика сербия":             "RS",
+	"сингапур":                      "SG",
+	"сирия":                         "SY",
+	"сирийская арабская республика": "SY",
+	"словакия":                      "SK",
+	"словацкая республика":          "SK",
+	"словения":                      "SI",
+	"республика словения":           "SI",
+	"судан":                         "SD",
+	"сша":                           "US",
+	"соединенные штаты америки":     "US",
+	"сьерра-леоне":                  "SL",
+	"таджикистан":                   "TJ",
+	"республика таджикистан":        "TJ",
+	"таиланд":                       "TH",
+	"королевство таиланд":           "TH",
+	"танзания":                      "TZ",
+	"тунис":                         "TN",
+	"тунисская республика":          "TN",
+	"туркменистан":                  "TM",
+	"туркмения":                     "TM",
+	"турция":                        "TR",
+	"турецкая республика":           "TR",
+	"уганда":                        "UG",
+	"узбекистан":                    "UZ",
+	"республика узбекистан":         "UZ",
+	"украина":                       "UA",
+	"уругвай":                       "UY",
+	"филиппины":                     "PH",
+	"республика филиппины":          "PH",
+	"финляндия":                     "FI",
+	"финляндская республика":        "FI",
+	"франция":                       "FR",
+	"французская республика":        "FR",
+	"хорватия":                      "HR",
+	"республика хорватия":           "HR",
+	"цар":                           "CF",
+	"центральноафриканская республика": "CF",
+	"чад":                "TD",
+	"черногория":         "ME",
+	"чехия":              "CZ",
+	"чешская республика": "CZ",
+	"чили":               "CL",
+	"швейцария":          "CH",
+	"швейцарская конфедерация": "CH",
+	"швеция":                "SE",
+	"королевство швеция":    "SE",
+	"шри-ланка":             "LK",
+	"эквадор":               "EC",
+	"экваториальная гвинея": "GQ",
+	"эритрея":               "ER",
+	"эстония":               "EE",
+	"эстонская республика":  "EE",
+	"эфиопия":               "ET",
+	"юар":                   "ZA",
+	"южно-африканская республика": "ZA",
+	"южный судан":                 "SS",
+	"ямайка":                      "JM",
+	"япония":                      "JP",
+}
+
+// countryCode looks up the ISO 3166-1 alpha-2 code of the CIK country name state.
+func countryCode(state string) (string, bool) {
+	code, ok := countryCodes[normalize(state)]
+	return code, ok
+}
@@ -2,12 +2,10 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/google/uuid"
 	ls "github.com/schollz/closestmatch/levenshtein"
 	"io"
 	"net/http"
@@ -29,20 +27,6 @@ func (hl httpLogger) RoundTrip(request *http.Request) (*http.Response, error) {
 	return hl.next.RoundTrip(request)
 }
 
-type closableReader struct {
-	r io.Reader
-}
-
-var _ io.ReadCloser = closableReader{}
-
-func (cr closableReader) Read(p []byte) (int, error) {
-	return cr.r.Read(p)
-}
-
-func (cr closableReader) Close() error {
-	return nil
-}
-
 var pollingStation = regexp.MustCompile(`(?m)\s*\(.*?\)\s*\z`)
 var electDistrict = regexp.MustCompile(`(?m)\A\S.+?\d+.+?|\s+одномандатный\s+избирательный\s+округ\s*\z`)
 
@@ -50,7 +34,10 @@ func main() {
 	cikCsv := flag.String("data", "", "FILE")
 	uRL := flag.String("url", "", "URL")
 	user := flag.String("user", "", "USERNAME")
+	token := flag.String("token", os.Getenv("TOKEN"), "TOKEN")
 	force := flag.Bool("force", false, "")
+	prune := flag.Bool("prune", false, "")
+	publish := flag.Bool("publish", false, "")
 	flag.Parse()
 
 	if strings.TrimSpace(*cikCsv) == "" {
@@ -63,15 +50,20 @@ func main() {
 		os.Exit(2)
 	}
 
-	if strings.TrimSpace(*user) == "" {
-		fmt.Fprintln(os.Stderr, "-user missing")
-		os.Exit(2)
-	}
-
 	pass := os.Getenv("PASSWORD")
-	if strings.TrimSpace(pass) == "" {
-		fmt.Fprintln(os.Stderr, "$PASSWORD missing")
-		os.Exit(2)
+
+	// A token (needs states:write, offices:write, stations:write, districts:write, drafts:read
+	// and for -publish publish:write) replaces -user and $PASSWORD.
+	if strings.TrimSpace(*token) == "" {
+		if strings.TrimSpace(*user) == "" {
+			fmt.Fprintln(os.Stderr, "-user (or -token) missing")
+			os.Exit(2)
+		}
+
+		if strings.TrimSpace(pass) == "" {
+			fmt.Fprintln(os.Stderr, "$PASSWORD missing")
+			os.Exit(2)
+		}
 	}
 
 	baseUrl, errPU := url.Parse(*uRL)
@@ -87,7 +79,7 @@ func main() {
 	}
 
 	reader := csv.NewReader(bufio.NewReader(data))
-	states := map[string]map[string]struct{}{}
+	states := map[string]map[string]map[string]string{}
 	districts := map[string]struct{}{}
 
 	for {
@@ -101,17 +93,32 @@ func main() {
 			os.Exit(1)
 		}
 
-		if len(row) > 3 {
+		if len(row) > 4 {
 			state := strings.TrimSpace(row[3])
 			offices, ok := states[state]
 
 			if !ok {
-				offices = map[string]struct{}{}
+				offices = map[string]map[string]string{}
 				states[state] = offices
 			}
 
-			offices[strings.TrimSpace(pollingStation.ReplaceAllLiteralString(row[4], ""))] = struct{}{}
-			districts[electDistrict.ReplaceAllLiteralString(strings.TrimSpace(row[1]), "")] = struct{}{}
+			office := strings.TrimSpace(pollingStation.ReplaceAllLiteralString(row[4], ""))
+			stations, ok := offices[office]
+
+			if !ok {
+				stations = map[string]string{}
+				offices[office] = stations
+			}
+
+			station := strings.TrimSpace(strings.Trim(strings.TrimSpace(pollingStation.FindString(row[4])), "()"))
+			if station == "" {
+				station = office
+			}
+
+			district := electDistrict.ReplaceAllLiteralString(strings.TrimSpace(row[1]), "")
+
+			stations[station] = district
+			districts[district] = struct{}{}
 		}
 	}
 
@@ -119,7 +126,17 @@ func main() {
 	delete(districts, "")
 
 	if !*force {
-		fmt.Fprintf(os.Stderr, "Would have created %d states and %d districts\n\n", len(states), len(districts))
+		var nStations int
+		for _, offices := range states {
+			for _, stations := range offices {
+				nStations += len(stations)
+			}
+		}
+
+		fmt.Fprintf(
+			os.Stderr, "Would have created %d states, %d stations and %d districts\n\n",
+			len(states), nStations, len(districts),
+		)
 
 		uniqStr := make(map[string]struct{}, len(states))
 
@@ -132,13 +149,26 @@ func main() {
 
 				buf.Write([]byte("- state: "))
 				json.NewEncoder(buf).Encode(state)
+
+				if code, ok := countryCode(state); ok {
+					buf.Write([]byte("  iso_code: " + code + "\n"))
+				}
+
 				buf.Write([]byte("  offices:\n"))
 
-				for office := range offices {
+				for office, stations := range offices {
 					uniqStr[office] = struct{}{}
 
-					buf.Write([]byte("  - "))
+					buf.Write([]byte("  - office: "))
 					json.NewEncoder(buf).Encode(office)
+					buf.Write([]byte("    stations:\n"))
+
+					for station, district := range stations {
+						buf.Write([]byte("    - station: "))
+						json.NewEncoder(buf).Encode(station)
+						buf.Write([]byte("      district: "))
+						json.NewEncoder(buf).Encode(district)
+					}
 				}
 			}
 
@@ -185,7 +215,7 @@ func main() {
 				return distances[i].distance < distances[j].distance
 			})
 
-			fmt.Fprintln(os.Stderr, "\nLevenshtein distance:\n")
+			fmt.Fprint(os.Stderr, "\nLevenshtein distance:\n\n")
 
 			for _, d := range distances {
 				fmt.Fprintf(os.Stderr, "%d  %#v vs. %#v\n", d.distance, d.lhs, d.rhs)
@@ -194,110 +224,17 @@ func main() {
 		return
 	}
 
-	client := http.Client{Transport: httpLogger{http.DefaultTransport}}
-	req := http.Request{Method: "PUT", URL: baseUrl, Header: http.Header{}}
-
-	req.SetBasicAuth(*user, pass)
-
-	for state, offices := range states {
-		buf := &bytes.Buffer{}
-
-		{
-			errEc := json.NewEncoder(buf).Encode(struct {
-				RuName string `json:"ru_name"`
-			}{state})
-			if errEc != nil {
-				fmt.Fprintln(os.Stderr, errEc.Error())
-				os.Exit(1)
-			}
-		}
-
-		baseUrl.Path = "/v1/states"
-		req.Body = closableReader{buf}
-
-		resp, errDR := client.Do(&req)
-		if errDR != nil {
-			fmt.Fprintln(os.Stderr, errDR.Error())
-			os.Exit(1)
-		}
-
-		if resp.StatusCode != 201 {
-			fmt.Fprintf(os.Stderr, "HTTP %d\n", resp.StatusCode)
-			os.Exit(1)
-		}
-
-		{
-			var rb struct {
-				Id uuid.UUID `json:"id"`
-			}
-
-			if errDc := json.NewDecoder(bufio.NewReader(resp.Body)).Decode(&rb); errDc != nil {
-				fmt.Fprintln(os.Stderr, errDc.Error())
-				os.Exit(1)
-			}
-
-			baseUrl.Path = "/v1/states/" + rb.Id.String() + "/offices"
-		}
-
-		resp.Body.Close()
-
-		for office := range offices {
-			buf := &bytes.Buffer{}
-
-			{
-				errEc := json.NewEncoder(buf).Encode(struct {
-					RuName string `json:"ru_name"`
-				}{office})
-				if errEc != nil {
-					fmt.Fprintln(os.Stderr, errEc.Error())
-					os.Exit(1)
-				}
-			}
-
-			req.Body = closableReader{buf}
-
-			resp, errDR := client.Do(&req)
-			if errDR != nil {
-				fmt.Fprintln(os.Stderr, errDR.Error())
-				os.Exit(1)
-			}
-
-			if resp.StatusCode != 204 {
-				fmt.Fprintf(os.Stderr, "HTTP %d\n", resp.StatusCode)
-				os.Exit(1)
-			}
-
-			resp.Body.Close()
-		}
+	c := &client{
+		Client: http.Client{Transport: httpLogger{http.DefaultTransport}},
+		base:   *baseUrl,
+		user:   *user,
+		pass:   pass,
+		token:  strings.TrimSpace(*token),
 	}
 
-	baseUrl.Path = "/v1/districts"
-	for district := range districts {
-		buf := &bytes.Buffer{}
-
-		{
-			errEc := json.NewEncoder(buf).Encode(struct {
-				RuName string `json:"ru_name"`
-			}{district})
-			if errEc != nil {
-				fmt.Fprintln(os.Stderr, errEc.Error())
-				os.Exit(1)
-			}
-		}
-
-		req.Body = closableReader{buf}
-
-		resp, errDR := client.Do(&req)
-		if errDR != nil {
-			fmt.Fprintln(os.Stderr, errDR.Error())
-			os.Exit(1)
-		}
-
-		if resp.StatusCode != 204 {
-			fmt.Fprintf(os.Stderr, "HTTP %d\n", resp.StatusCode)
-			os.Exit(1)
-		}
+	syncAll(c, states, districts, *prune)
 
-		resp.Body.Close()
+	if *publish {
+		c.call("POST", "/v1/publish", nil, nil, 200)
 	}
 }
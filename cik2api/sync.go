@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type client struct {
+	http.Client
+
+	base       url.URL
+	user, pass string
+	// token is used instead of user and pass if given.
+	token string
+}
+
+func (c *client) call(method, path string, payload, result interface{}, expect int) {
+	if status := c.try(method, path, payload, result); status != expect {
+		fmt.Fprintf(os.Stderr, "HTTP %d\n", status)
+		os.Exit(1)
+	}
+}
+
+// try is like call, but returns the HTTP status instead of demanding a particular one.
+// It decodes the response into result only on success.
+func (c *client) try(method, path string, payload, result interface{}) int {
+	buf := &bytes.Buffer{}
+
+	if payload != nil {
+		if errEc := json.NewEncoder(buf).Encode(payload); errEc != nil {
+			fmt.Fprintln(os.Stderr, errEc.Error())
+			os.Exit(1)
+		}
+	}
+
+	uRL := c.base
+	uRL.Path = path
+
+	// Drafts we've created before have to be seen to not create them again.
+	if method == "GET" {
+		uRL.RawQuery = "include=drafts"
+	}
+
+	req, errNR := http.NewRequest(method, uRL.String(), buf)
+	if errNR != nil {
+		fmt.Fprintln(os.Stderr, errNR.Error())
+		os.Exit(1)
+	}
+
+	if c.token == "" {
+		req.SetBasicAuth(c.user, c.pass)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, errDR := c.Do(req)
+	if errDR != nil {
+		fmt.Fprintln(os.Stderr, errDR.Error())
+		os.Exit(1)
+	}
+
+	defer resp.Body.Close()
+
+	if result != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if errDc := json.NewDecoder(bufio.NewReader(resp.Body)).Decode(result); errDc != nil {
+			fmt.Fprintln(os.Stderr, errDc.Error())
+			os.Exit(1)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+// entity is both what the API serves and what it accepts for all kinds of entities.
+type entity struct {
+	RuName   string     `json:"ru_name"`
+	District *uuid.UUID `json:"district,omitempty"`
+	IsoCode  *string    `json:"iso_code,omitempty"`
+}
+
+// equals tells whether the existing entity e is up to date with the wanted one. The API never
+// unsets ISO codes, so one we don't know a code for is up to date with any code.
+func (e entity) equals(other entity) bool {
+	if e.RuName != other.RuName {
+		return false
+	}
+
+	if other.IsoCode != nil && (e.IsoCode == nil || *e.IsoCode != *other.IsoCode) {
+		return false
+	}
+
+	if e.District == nil || other.District == nil {
+		return e.District == other.District
+	}
+
+	return *e.District == *other.District
+}
+
+func named(names map[uuid.UUID]string) map[uuid.UUID]entity {
+	res := make(map[uuid.UUID]entity, len(names))
+	for id, name := range names {
+		res[id] = entity{RuName: name}
+	}
+
+	return res
+}
+
+func normalize(name string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(strings.ToLower(name), "ё", "е")), " ")
+}
+
+// reconcile matches the wanted entities against the existing ones by ISO code if any, otherwise by
+// normalized name. It creates the missing ones via PUT to collection, updates the differing ones
+// via POST to item + ID and returns the IDs of all wanted entities as well as the existing ones not
+// wanted anymore.
+func (c *client) reconcile(
+	kind string, want map[string]entity, have map[uuid.UUID]entity, collection, item string,
+) (map[string]uuid.UUID, map[uuid.UUID]string) {
+	byNorm := make(map[string]uuid.UUID, len(have))
+	byCode := map[string]uuid.UUID{}
+	stale := map[uuid.UUID]string{}
+
+	{
+		existing := make([]uuid.UUID, 0, len(have))
+		for id := range have {
+			existing = append(existing, id)
+		}
+
+		sort.Slice(existing, func(i, j int) bool {
+			return existing[i].String() < existing[j].String()
+		})
+
+		for _, id := range existing {
+			if code := have[id].IsoCode; code != nil {
+				byCode[*code] = id
+			}
+
+			norm := normalize(have[id].RuName)
+
+			if _, ok := byNorm[norm]; ok {
+				stale[id] = have[id].RuName
+			} else {
+				byNorm[norm] = id
+			}
+		}
+	}
+
+	wanted := make([]string, 0, len(want))
+	for name := range want {
+		wanted = append(wanted, name)
+	}
+
+	sort.Strings(wanted)
+
+	ids := make(map[string]uuid.UUID, len(want))
+	used := map[uuid.UUID]struct{}{}
+
+	for _, name := range wanted {
+		id, ok := byNorm[normalize(name)]
+		if code := want[name].IsoCode; code != nil {
+			if byIso, ok2 := byCode[*code]; ok2 {
+				id, ok = byIso, true
+			}
+		}
+
+		if ok {
+			ids[name] = id
+
+			if _, ok := used[id]; !ok {
+				used[id] = struct{}{}
+
+				if !have[id].equals(want[name]) {
+					fmt.Fprintf(os.Stderr, "Updating %s %#v as %#v\n", kind, have[id].RuName, name)
+					c.call("POST", item+id.String(), want[name], nil, 204)
+				}
+			}
+		} else {
+			var rb struct {
+				Id uuid.UUID `json:"id"`
+			}
+
+			c.call("PUT", collection, want[name], &rb, 201)
+
+			ids[name] = rb.Id
+			byNorm[normalize(name)] = rb.Id
+			used[rb.Id] = struct{}{}
+		}
+	}
+
+	for _, id := range byNorm {
+		if _, ok := used[id]; !ok {
+			stale[id] = have[id].RuName
+		}
+	}
+
+	for id := range stale {
+		if _, ok := used[id]; ok {
+			delete(stale, id)
+		}
+	}
+
+	for id, name := range stale {
+		fmt.Fprintf(os.Stderr, "Stale %s %#v (%s)\n", kind, name, id.String())
+	}
+
+	return ids, stale
+}
+
+func syncAll(
+	c *client, states map[string]map[string]map[string]string, districts map[string]struct{}, prune bool,
+) {
+	var staleStations, staleOffices []uuid.UUID
+
+	var haveDistricts map[uuid.UUID]string
+	c.call("GET", "/v1/districts", nil, &haveDistricts, 200)
+
+	districtIds, staleDistricts := c.reconcile(
+		"district", toEntities(districts), named(haveDistricts), "/v1/districts", "/v1/districts/",
+	)
+
+	var haveStateNames map[uuid.UUID]string
+	c.call("GET", "/v1/states", nil, &haveStateNames, 200)
+
+	wantStates := toEntities(states)
+	haveStates := named(haveStateNames)
+
+	{
+		names := make([]string, 0, len(wantStates))
+		for name := range wantStates {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		claimed := map[string]string{}
+
+		for _, name := range names {
+			code, ok := countryCode(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "No ISO code known for state %#v\n", name)
+				continue
+			}
+
+			if other, ok := claimed[code]; ok {
+				fmt.Fprintf(os.Stderr, "States %#v and %#v share ISO code %s\n", other, name, code)
+				continue
+			}
+
+			claimed[code] = name
+			state := wantStates[name]
+			state.IsoCode = &code
+			wantStates[name] = state
+
+			var byCode struct {
+				Id      uuid.UUID `json:"id"`
+				RuName  string    `json:"ru_name"`
+				IsoCode string    `json:"iso_code"`
+			}
+
+			switch status := c.try("GET", "/v1/states/by-code/"+code, nil, &byCode); status {
+			case 200:
+				haveStates[byCode.Id] = entity{RuName: byCode.RuName, IsoCode: &byCode.IsoCode}
+			case 404:
+			default:
+				fmt.Fprintf(os.Stderr, "HTTP %d\n", status)
+				os.Exit(1)
+			}
+		}
+	}
+
+	stateIds, staleStates := c.reconcile("state", wantStates, haveStates, "/v1/states", "/v1/states/")
+
+	for state, offices := range states {
+		path := "/v1/states/" + stateIds[state].String() + "/offices"
+
+		var haveOffices map[uuid.UUID]string
+		c.call("GET", path, nil, &haveOffices, 200)
+
+		officeIds, stale := c.reconcile("office", toEntities(offices), named(haveOffices), path, "/v1/offices/")
+
+		for id := range stale {
+			staleOffices = append(staleOffices, id)
+		}
+
+		for office, stations := range offices {
+			path := "/v1/offices/" + officeIds[office].String() + "/stations"
+			want := make(map[string]entity, len(stations))
+			skipped := map[string]struct{}{}
+
+			for station, district := range stations {
+				if id, ok := districtIds[district]; ok {
+					want[station] = entity{RuName: station, District: &id}
+				} else {
+					fmt.Fprintf(os.Stderr, "Skipping station %#v without district\n", station)
+					skipped[normalize(station)] = struct{}{}
+				}
+			}
+
+			var haveStations map[uuid.UUID]entity
+			c.call("GET", path, nil, &haveStations, 200)
+
+			_, stale := c.reconcile("station", want, haveStations, path, "/v1/stations/")
+
+			// Incomplete data is no reason to prune what we have.
+			for id, name := range stale {
+				if _, ok := skipped[normalize(name)]; ok {
+					fmt.Fprintf(os.Stderr, "Keeping station %#v (%s) without district\n", name, id.String())
+				} else {
+					staleStations = append(staleStations, id)
+				}
+			}
+		}
+	}
+
+	for id := range staleStates {
+		var haveOffices map[uuid.UUID]string
+		c.call("GET", "/v1/states/"+id.String()+"/offices", nil, &haveOffices, 200)
+
+		for office, name := range haveOffices {
+			fmt.Fprintf(os.Stderr, "Stale office %#v (%s)\n", name, office.String())
+			staleOffices = append(staleOffices, office)
+		}
+	}
+
+	for _, office := range staleOffices {
+		var haveStations map[uuid.UUID]entity
+		c.call("GET", "/v1/offices/"+office.String()+"/stations", nil, &haveStations, 200)
+
+		for id, station := range haveStations {
+			fmt.Fprintf(os.Stderr, "Stale station %#v (%s)\n", station.RuName, id.String())
+			staleStations = append(staleStations, id)
+		}
+	}
+
+	if prune {
+		for _, id := range staleStations {
+			c.call("DELETE", "/v1/stations/"+id.String(), nil, nil, 204)
+		}
+
+		for _, id := range staleOffices {
+			c.call("DELETE", "/v1/offices/"+id.String(), nil, nil, 204)
+		}
+
+		for id := range staleStates {
+			c.call("DELETE", "/v1/states/"+id.String(), nil, nil, 204)
+		}
+
+		for id := range staleDistricts {
+			c.call("DELETE", "/v1/districts/"+id.String(), nil, nil, 204)
+		}
+	}
+}
+
+func toEntities(names interface{}) map[string]entity {
+	keys := reflect.ValueOf(names).MapKeys()
+	res := make(map[string]entity, len(keys))
+
+	for _, key := range keys {
+		res[key.String()] = entity{RuName: key.String()}
+	}
+
+	return res
+}